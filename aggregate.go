@@ -6,6 +6,7 @@ type (
 		state    T
 		versions Versions
 		events   []Event[T]
+		metadata []Metadata
 	}
 
 	// Versions represents aggergate versions
@@ -31,15 +32,10 @@ func NewAggregate[T any](s VersionedState[T], es ...Event[T]) (*Aggregate[T], er
 		},
 	}
 
-	var err error
 	for _, e := range es {
-		a.state, err = e.Apply(a.state)
-		if err != nil {
+		if err := a.load(e, Metadata{}); err != nil {
 			return nil, err
 		}
-
-		a.versions.Initial++
-		a.versions.Current++
 	}
 
 	return a, nil
@@ -60,6 +56,11 @@ func (a *Aggregate[T]) Events() []Event[T] {
 	return a.events
 }
 
+// Metadata returns the metadata for all events applied to the state snapshot
+func (a *Aggregate[T]) Metadata() []Metadata {
+	return a.metadata
+}
+
 // Apply applies the specified event
 func (a *Aggregate[T]) Apply(e Event[T]) (uint64, error) {
 	ns, err := e.Apply(a.state)
@@ -76,5 +77,35 @@ func (a *Aggregate[T]) Apply(e Event[T]) (uint64, error) {
 		a.events = append(a.events, e)
 	}
 
+	a.metadata = append(a.metadata, newMetadata())
+
 	return a.versions.Current, nil
 }
+
+// Commit marks all applied events as committed, advancing the initial version to the current version.
+// It is called by Store.Save once the events have been persisted.
+func (a *Aggregate[T]) Commit() {
+	a.versions.Initial = a.versions.Current
+	a.events = nil
+}
+
+// load applies the specified persisted event
+func (a *Aggregate[T]) load(e Event[T], m Metadata) error {
+	ns, err := e.Apply(a.state)
+	if err != nil {
+		return err
+	}
+
+	a.state = ns
+	a.versions.Initial++
+	a.versions.Current++
+	a.metadata = append(a.metadata, m)
+
+	return nil
+}
+
+// commitState marks the current state as persisted in a snapshot
+func (a *Aggregate[T]) commitState() {
+	a.versions.State = a.versions.Current
+	a.metadata = nil
+}
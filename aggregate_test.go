@@ -108,11 +108,53 @@ func TestAggregate_Apply(t *testing.T) {
 				}
 			}
 
+			if tt.err {
+				return
+			}
+
+			ms := tt.sut.Metadata()
+			if act, exp := len(ms), len(tt.events); act != exp {
+				t.Fatalf("got %d, expected %d", act, exp)
+			}
+
+			for _, m := range ms {
+				if m.EventID == "" || m.Timestamp.IsZero() {
+					t.Errorf("got %v, expected event id and timestamp", m)
+				}
+			}
+
 			assertAggregateEqual(t, tt.sut, tt.exp)
 		})
 	}
 }
 
+func TestAggregate_Commit(t *testing.T) {
+	sut := newAggregate(salsa.VersionedState[state]{
+		Version: 4,
+		State:   state{Balance: 10},
+	})
+
+	for _, e := range []salsa.Event[state]{&event{Amount: 5}, &event{Amount: 10}} {
+		_, err := sut.Apply(e)
+		assertErrorExists(t, err, false)
+	}
+
+	sut.Commit()
+
+	assertAggregateEqual(t, sut, aggregate{
+		state: state{Balance: 25},
+		versions: salsa.Versions{
+			State:   4,
+			Initial: 6,
+			Current: 6,
+		},
+	})
+
+	if act, exp := len(sut.Metadata()), 2; act != exp {
+		t.Errorf("got %d, expected %d", act, exp)
+	}
+}
+
 type aggregate struct {
 	state    state
 	versions salsa.Versions
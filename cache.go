@@ -0,0 +1,119 @@
+package salsa
+
+import (
+	"container/list"
+	"sync"
+)
+
+type (
+	// cache is a fixed size least recently used cache of aggregate states
+	cache[TI comparable, TS any] struct {
+		size  int
+		ll    *list.List
+		items map[TI]*list.Element
+		mu    sync.Mutex
+	}
+
+	// cacheEntry represents a cached aggregate state, along with the snapshot version
+	// and event metadata required to evaluate the snapshot policy
+	cacheEntry[TI comparable, TS any] struct {
+		id       TI
+		state    VersionedState[TS]
+		snapshot uint64
+		metadata []Metadata
+	}
+)
+
+// WithCache configures the store to cache up to the specified number of aggregate states in memory.
+// Cached states are shared between aggregates, so events must not modify existing state in place.
+func WithCache[T any](size int) func(*Options[T]) {
+	return func(o *Options[T]) {
+		o.CacheSize = size
+	}
+}
+
+func newCache[TI comparable, TS any](size int) *cache[TI, TS] {
+	return &cache[TI, TS]{
+		size:  size,
+		ll:    list.New(),
+		items: map[TI]*list.Element{},
+	}
+}
+
+// get returns the cached entry for the specified id, marking it as recently used
+func (c *cache[TI, TS]) get(id TI) (cacheEntry[TI, TS], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[id]
+	if !ok {
+		return cacheEntry[TI, TS]{}, false
+	}
+
+	c.ll.MoveToFront(el)
+	return el.Value.(cacheEntry[TI, TS]), true
+}
+
+// put caches the committed state of the specified aggregate, evicting the least recently used entry if required
+func (c *cache[TI, TS]) put(id TI, a *Aggregate[TS]) {
+	e := cacheEntry[TI, TS]{
+		id:       id,
+		state:    VersionedState[TS]{Version: a.versions.Current, State: a.state},
+		snapshot: a.versions.State,
+		metadata: append([]Metadata(nil), a.metadata...),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[id]; ok {
+		// a concurrent read may have cached a newer state
+		if el.Value.(cacheEntry[TI, TS]).state.Version > e.state.Version {
+			return
+		}
+
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[id] = c.ll.PushFront(e)
+	if c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(cacheEntry[TI, TS]).id)
+	}
+}
+
+// remove removes the entry for the specified id
+func (c *cache[TI, TS]) remove(id TI) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[id]; ok {
+		c.ll.Remove(el)
+		delete(c.items, id)
+	}
+}
+
+// clear removes all entries
+func (c *cache[TI, TS]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = map[TI]*list.Element{}
+}
+
+// aggregate returns a new aggregate with the cached state
+func (e cacheEntry[TI, TS]) aggregate() *Aggregate[TS] {
+	return &Aggregate[TS]{
+		state: e.state.State,
+		versions: Versions{
+			State:   e.snapshot,
+			Initial: e.state.Version,
+			Current: e.state.Version,
+		},
+		metadata: append([]Metadata(nil), e.metadata...),
+	}
+}
@@ -0,0 +1,180 @@
+package salsa_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stevecallear/salsa"
+)
+
+func TestWithCache(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	newAggregate := func(n int) *salsa.Aggregate[state] {
+		a := new(salsa.Aggregate[state])
+		for i := 0; i < n; i++ {
+			if _, err := a.Apply(&event{Amount: 10}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return a
+	}
+
+	tests := []struct {
+		name  string
+		setup func(*salsa.Store[string, state], salsa.DB[string])
+		exp   salsa.Versions
+		state state
+		reads []string
+	}{
+		{
+			name: "should read the aggregate if it is not cached",
+			setup: func(_ *salsa.Store[string, state], db salsa.DB[string]) {
+				sut := salsa.NewStore[string](db, salsa.WithResolver[state](er))
+				if err := sut.Save(context.Background(), "id", newAggregate(3)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			exp:   salsa.Versions{State: 0, Initial: 3, Current: 3},
+			state: state{Balance: 30},
+			reads: []string{"read"},
+		},
+		{
+			name: "should read subsequent events if the aggregate is cached",
+			setup: func(sut *salsa.Store[string, state], db salsa.DB[string]) {
+				if err := sut.Save(context.Background(), "id", newAggregate(3)); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := sut.Append(context.Background(), "id", 3, &event{Amount: 10}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			exp:   salsa.Versions{State: 0, Initial: 4, Current: 4},
+			state: state{Balance: 40},
+			reads: []string{"events:4"},
+		},
+		{
+			name: "should cache the snapshot version",
+			setup: func(sut *salsa.Store[string, state], db salsa.DB[string]) {
+				if err := sut.Save(context.Background(), "id", newAggregate(6)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			exp:   salsa.Versions{State: 6, Initial: 6, Current: 6},
+			state: state{Balance: 60},
+			reads: []string{"events:7"},
+		},
+		{
+			name: "should invalidate the cache on conflict",
+			setup: func(sut *salsa.Store[string, state], db salsa.DB[string]) {
+				if err := sut.Save(context.Background(), "id", newAggregate(3)); err != nil {
+					t.Fatal(err)
+				}
+
+				err := sut.Save(context.Background(), "id", newAggregate(1))
+				if !errors.Is(err, salsa.ErrConflict) {
+					t.Fatalf("got %v, expected %v", err, salsa.ErrConflict)
+				}
+			},
+			exp:   salsa.Versions{State: 0, Initial: 3, Current: 3},
+			state: state{Balance: 30},
+			reads: []string{"read"},
+		},
+		{
+			name: "should evict the least recently used aggregate",
+			setup: func(sut *salsa.Store[string, state], db salsa.DB[string]) {
+				for _, id := range []string{"id", "other1", "other2"} {
+					if err := sut.Save(context.Background(), id, newAggregate(3)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			exp:   salsa.Versions{State: 0, Initial: 3, Current: 3},
+			state: state{Balance: 30},
+			reads: []string{"read"},
+		},
+		{
+			name: "should invalidate the cache when snapshots are purged",
+			setup: func(sut *salsa.Store[string, state], db salsa.DB[string]) {
+				if err := sut.Save(context.Background(), "id", newAggregate(6)); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := sut.PurgeSnapshots(context.Background(), "id"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			exp:   salsa.Versions{State: 0, Initial: 6, Current: 6},
+			state: state{Balance: 60},
+			reads: []string{"read"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &readRecorderDB{DB: salsa.NewMemoryDB[string]()}
+			sut := salsa.NewStore[string](db,
+				salsa.WithResolver[state](er),
+				salsa.WithSnapshotRate[state](5),
+				salsa.WithCache[state](2))
+
+			tt.setup(sut, db)
+			db.reads = nil
+
+			act, err := sut.Get(context.Background(), "id")
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act.Versions(), tt.exp)
+			assertDeepEqual(t, act.State(), tt.state)
+			assertDeepEqual(t, db.reads, tt.reads)
+		})
+	}
+
+	t.Run("should return not found if the aggregate does not exist", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er), salsa.WithCache[state](2))
+
+		_, err := sut.Get(context.Background(), "id")
+		if !errors.Is(err, salsa.ErrNotFound) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrNotFound)
+		}
+	})
+
+	t.Run("should not share metadata between cached aggregates", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er), salsa.WithCache[state](2))
+
+		err := sut.Save(context.Background(), "id", newAggregate(2))
+		assertErrorExists(t, err, false)
+
+		a1, err := sut.Get(context.Background(), "id")
+		assertErrorExists(t, err, false)
+
+		a2, err := sut.Get(context.Background(), "id")
+		assertErrorExists(t, err, false)
+
+		_, err = a1.Apply(&event{Amount: 10})
+		assertErrorExists(t, err, false)
+
+		assertDeepEqual(t, len(a1.Metadata()), 3)
+		assertDeepEqual(t, len(a2.Metadata()), 2)
+	})
+}
+
+// readRecorderDB records aggregate reads
+type readRecorderDB struct {
+	salsa.DB[string]
+	reads []string
+}
+
+func (d *readRecorderDB) Read(ctx context.Context, id string) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	d.reads = append(d.reads, "read")
+	return d.DB.Read(ctx, id)
+}
+
+func (d *readRecorderDB) ReadEvents(ctx context.Context, id string, from, to uint64) ([]salsa.EncodedEvent, error) {
+	d.reads = append(d.reads, fmt.Sprintf("events:%d", from))
+	return d.DB.ReadEvents(ctx, id, from, to)
+}
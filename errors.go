@@ -0,0 +1,41 @@
+package salsa
+
+import (
+	"errors"
+	"fmt"
+)
+
+type (
+	// ConflictError represents an aggregate version conflict
+	ConflictError struct {
+		ID       any
+		Expected uint64
+		Actual   uint64
+	}
+)
+
+var (
+	// ErrNotFound indicates that the aggregate does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict indicates that an aggregate version conflict occurred
+	ErrConflict = errors.New("version conflict")
+
+	// ErrUnsupported indicates that the operation is not supported by the DB
+	ErrUnsupported = errors.New("operation not supported")
+
+	// ErrUnknownEvent indicates that the event resolver does not recognise the event type.
+	// Events of unknown types are skipped when reading the global stream, allowing stores
+	// for different aggregate types to share a DB.
+	ErrUnknownEvent = errors.New("unknown event type")
+)
+
+// Error returns the error message
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: aggregate %v expected version %d, actual version %d", ErrConflict, e.ID, e.Expected, e.Actual)
+}
+
+// Is returns true if the target is ErrConflict
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
@@ -0,0 +1,31 @@
+package salsa_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stevecallear/salsa"
+)
+
+func TestConflictError(t *testing.T) {
+	var err error = &salsa.ConflictError{ID: "id", Expected: 1, Actual: 2}
+
+	t.Run("should return the error message", func(t *testing.T) {
+		exp := "version conflict: aggregate id expected version 1, actual version 2"
+		if act := err.Error(); act != exp {
+			t.Errorf("got %s, expected %s", act, exp)
+		}
+	})
+
+	t.Run("should match the sentinel error", func(t *testing.T) {
+		if !errors.Is(err, salsa.ErrConflict) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrConflict)
+		}
+	})
+
+	t.Run("should not match other errors", func(t *testing.T) {
+		if errors.Is(err, salsa.ErrNotFound) {
+			t.Errorf("got %v, expected no match", err)
+		}
+	})
+}
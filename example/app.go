@@ -35,18 +35,12 @@ func (s *AccountService) CreateAccount(ctx context.Context, id string, balance i
 		}
 	}
 
-	return s.store.Save(ctx, id, a)
+	return s.store.Save(ctx, id, a, salsa.WithNewStream())
 }
 
 func (s *AccountService) CreditAccount(ctx context.Context, id string, amount int64) error {
-	a, err := s.store.Get(ctx, id)
-	if err != nil {
+	return s.store.Execute(ctx, id, func(a *salsa.Aggregate[AccountState]) error {
+		_, err := a.Apply(&CreditAccountEvent{Amount: amount})
 		return err
-	}
-
-	if _, err := a.Apply(&CreditAccountEvent{Amount: amount}); err != nil {
-		return err
-	}
-
-	return s.store.Save(ctx, id, a)
+	})
 }
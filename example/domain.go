@@ -28,7 +28,7 @@ var ResolveAccountEvent = salsa.EventResolverFunc[AccountState](func(eventType s
 	case new(CreditAccountEvent).Type():
 		return new(CreditAccountEvent), nil
 	default:
-		return nil, errors.New("invalid event type")
+		return nil, salsa.ErrUnknownEvent
 	}
 })
 
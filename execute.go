@@ -0,0 +1,101 @@
+package salsa
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+type (
+	// ExecuteOptions represents a set of command execution options
+	ExecuteOptions struct {
+		Retries    int
+		Backoff    time.Duration
+		MaxBackoff time.Duration
+		Jitter     float64
+	}
+)
+
+// Execute retrieves the aggregate with the specified id, invokes fn and saves the result. If a version
+// conflict occurs then the aggregate is reloaded and fn is invoked again, up to the configured number of
+// retries. The delay between attempts doubles from the initial backoff, up to the maximum backoff, and
+// is reduced by a random fraction of up to the configured jitter.
+func (s *Store[TI, TS]) Execute(ctx context.Context, id TI, fn func(*Aggregate[TS]) error, optFns ...func(*ExecuteOptions)) error {
+	o := ExecuteOptions{
+		Retries:    3,
+		Backoff:    10 * time.Millisecond,
+		MaxBackoff: time.Second,
+		Jitter:     0.5,
+	}
+
+	for _, optFn := range optFns {
+		optFn(&o)
+	}
+
+	d := o.Backoff
+	for i := 0; ; i++ {
+		err := s.execute(ctx, id, fn)
+		if !errors.Is(err, ErrConflict) || i >= o.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(o.delay(d)):
+		}
+
+		if d *= 2; d > o.MaxBackoff {
+			d = o.MaxBackoff
+		}
+	}
+}
+
+func (s *Store[TI, TS]) execute(ctx context.Context, id TI, fn func(*Aggregate[TS]) error) error {
+	a, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(a); err != nil {
+		return err
+	}
+
+	return s.Save(ctx, id, a)
+}
+
+func (o ExecuteOptions) delay(d time.Duration) time.Duration {
+	if o.Jitter <= 0 {
+		return d
+	}
+
+	j := o.Jitter
+	if j > 1 {
+		j = 1
+	}
+
+	return d - time.Duration(rand.Float64()*j*float64(d))
+}
+
+// WithRetries configures the maximum number of times a command is retried following a version conflict
+func WithRetries(n int) func(*ExecuteOptions) {
+	return func(o *ExecuteOptions) {
+		o.Retries = n
+	}
+}
+
+// WithBackoff configures the initial and maximum delay between command retries
+func WithBackoff(initial, max time.Duration) func(*ExecuteOptions) {
+	return func(o *ExecuteOptions) {
+		o.Backoff = initial
+		o.MaxBackoff = max
+	}
+}
+
+// WithJitter configures the maximum fraction of the retry delay that is randomised
+func WithJitter(f float64) func(*ExecuteOptions) {
+	return func(o *ExecuteOptions) {
+		o.Jitter = f
+	}
+}
@@ -0,0 +1,136 @@
+package salsa_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stevecallear/salsa"
+)
+
+func TestStore_Execute(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	tests := []struct {
+		name      string
+		conflicts int
+		optFns    []func(*salsa.ExecuteOptions)
+		calls     int
+		balance   int
+		err       error
+	}{
+		{
+			name:    "should execute the command",
+			calls:   1,
+			balance: 20,
+		},
+		{
+			name:      "should retry on conflict",
+			conflicts: 2,
+			calls:     3,
+			balance:   40,
+		},
+		{
+			name:      "should return an error if the retries are exceeded",
+			conflicts: 3,
+			optFns:    []func(*salsa.ExecuteOptions){salsa.WithRetries(2)},
+			calls:     3,
+			balance:   40,
+			err:       salsa.ErrConflict,
+		},
+		{
+			name:      "should not retry if retries are disabled",
+			conflicts: 1,
+			optFns:    []func(*salsa.ExecuteOptions){salsa.WithRetries(0)},
+			calls:     1,
+			balance:   20,
+			err:       salsa.ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const id = "id"
+			sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+
+			_, err := sut.Append(context.Background(), id, salsa.ExpectNoStream, &event{Amount: 10})
+			assertErrorExists(t, err, false)
+
+			var calls int
+			optFns := append([]func(*salsa.ExecuteOptions){
+				salsa.WithBackoff(time.Millisecond, 2*time.Millisecond),
+				salsa.WithJitter(0.5),
+			}, tt.optFns...)
+
+			err = sut.Execute(context.Background(), id, func(a *salsa.Aggregate[state]) error {
+				calls++
+				if calls <= tt.conflicts {
+					// simulate a concurrent write
+					if _, err := sut.Append(context.Background(), id, salsa.ExpectAny, &event{Amount: 10}); err != nil {
+						return err
+					}
+				}
+
+				_, err := a.Apply(&event{Amount: 10})
+				return err
+			}, optFns...)
+
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("got %v, expected %v", err, tt.err)
+			}
+			if tt.err == nil {
+				assertErrorExists(t, err, false)
+			}
+
+			assertDeepEqual(t, calls, tt.calls)
+
+			a, err := sut.Get(context.Background(), id)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, a.State(), state{Balance: tt.balance})
+		})
+	}
+
+	t.Run("should return command errors", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+
+		_, err := sut.Append(context.Background(), "id", salsa.ExpectNoStream, &event{Amount: 10})
+		assertErrorExists(t, err, false)
+
+		exp := errors.New("error")
+		err = sut.Execute(context.Background(), "id", func(*salsa.Aggregate[state]) error {
+			return exp
+		})
+
+		if !errors.Is(err, exp) {
+			t.Errorf("got %v, expected %v", err, exp)
+		}
+	})
+
+	t.Run("should return an error if the context is cancelled", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+
+		_, err := sut.Append(context.Background(), "id", salsa.ExpectNoStream, &event{Amount: 10})
+		assertErrorExists(t, err, false)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		err = sut.Execute(ctx, "id", func(a *salsa.Aggregate[state]) error {
+			if _, err := sut.Append(context.Background(), "id", salsa.ExpectAny, &event{Amount: 10}); err != nil {
+				return err
+			}
+
+			_, err := a.Apply(&event{Amount: 10})
+			return err
+		}, salsa.WithBackoff(time.Minute, time.Minute))
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, expected %v", err, context.Canceled)
+		}
+	})
+}
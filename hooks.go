@@ -0,0 +1,41 @@
+package salsa
+
+import "context"
+
+// SaveHook represents a hook that is invoked with the aggregate id, the encoded events and the decoded
+// events written by Store.Save, Store.SaveAll or Store.Append. Encoded event positions are not populated.
+type SaveHook[TS any] func(ctx context.Context, id any, ees []EncodedEvent, es []Event[TS]) error
+
+// WithBeforeSave configures the store to invoke the specified hooks in order before events are written.
+// A hook error prevents the events from being written. Hooks may be invoked more than once for the
+// same events if Append retries following a version conflict.
+func WithBeforeSave[T any](hs ...SaveHook[T]) func(*Options[T]) {
+	return func(o *Options[T]) {
+		o.BeforeSave = append(o.BeforeSave, hs...)
+	}
+}
+
+// WithAfterSave configures the store to invoke the specified hooks in order once events have been committed.
+// Hooks are invoked regardless of snapshot write errors. A hook error is returned by the store,
+// but does not indicate that the events were not saved.
+func WithAfterSave[T any](hs ...SaveHook[T]) func(*Options[T]) {
+	return func(o *Options[T]) {
+		o.AfterSave = append(o.AfterSave, hs...)
+	}
+}
+
+// runSaveHooks invokes the hooks in order, returning the first error.
+// Hooks are not invoked if there are no events.
+func runSaveHooks[TS any](ctx context.Context, hs []SaveHook[TS], id any, ees []EncodedEvent, es []Event[TS]) error {
+	if len(ees) < 1 {
+		return nil
+	}
+
+	for _, h := range hs {
+		if err := h(ctx, id, ees, es); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package salsa_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stevecallear/salsa"
+)
+
+func TestWithSaveHooks(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	errHook := errors.New("error")
+
+	tests := []struct {
+		name    string
+		before  error
+		after   error
+		calls   []string
+		version uint64
+		err     error
+	}{
+		{
+			name:    "should invoke the hooks in order",
+			calls:   []string{"before1:id:1,2", "before2:id:1,2", "after1:id:1,2", "after2:id:1,2"},
+			version: 2,
+		},
+		{
+			name:   "should not write the events if a before hook fails",
+			before: errHook,
+			calls:  []string{"before1:id:1,2"},
+			err:    errHook,
+		},
+		{
+			name:    "should return after hook errors",
+			after:   errHook,
+			calls:   []string{"before1:id:1,2", "before2:id:1,2", "after1:id:1,2"},
+			version: 2,
+			err:     errHook,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			hook := func(name string, err error) salsa.SaveHook[state] {
+				return func(ctx context.Context, id any, ees []salsa.EncodedEvent, es []salsa.Event[state]) error {
+					if len(ees) != len(es) {
+						t.Errorf("got %d encoded events, expected %d", len(ees), len(es))
+					}
+
+					c := fmt.Sprintf("%s:%v:", name, id)
+					for i, ee := range ees {
+						if i > 0 {
+							c += ","
+						}
+						c += fmt.Sprint(ee.Version)
+					}
+
+					calls = append(calls, c)
+					return err
+				}
+			}
+
+			db := salsa.NewMemoryDB[string]()
+			sut := salsa.NewStore[string](db,
+				salsa.WithResolver[state](er),
+				salsa.WithBeforeSave(hook("before1", tt.before), hook("before2", nil)),
+				salsa.WithAfterSave(hook("after1", tt.after), hook("after2", nil)))
+
+			a := new(salsa.Aggregate[state])
+			for i := 0; i < 2; i++ {
+				_, err := a.Apply(&event{Amount: 10})
+				assertErrorExists(t, err, false)
+			}
+
+			err := sut.Save(context.Background(), "id", a)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, expected %v", err, tt.err)
+			}
+
+			assertDeepEqual(t, calls, tt.calls)
+
+			var act uint64
+			if _, es, err := db.Read(context.Background(), "id"); err == nil {
+				act = es[len(es)-1].Version
+			}
+			assertDeepEqual(t, act, tt.version)
+		})
+	}
+
+	t.Run("should invoke the hooks for appended events", func(t *testing.T) {
+		var act []salsa.Event[state]
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er),
+			salsa.WithAfterSave(func(ctx context.Context, id any, ees []salsa.EncodedEvent, es []salsa.Event[state]) error {
+				act = append(act, es...)
+				return nil
+			}))
+
+		e := &event{Amount: 10}
+		_, err := sut.Append(context.Background(), "id", salsa.ExpectAny, e)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act, []salsa.Event[state]{e})
+	})
+
+	t.Run("should invoke the after hooks if the snapshot write fails", func(t *testing.T) {
+		var act []any
+		sut := salsa.NewStoreWithSnapshots[string](salsa.NewMemoryDB[string](),
+			&errSnapshotStore{SnapshotStore: salsa.NewMemorySnapshotStore[string](), err: errors.New("error")},
+			salsa.WithResolver[state](er),
+			salsa.WithSnapshotRate[state](1),
+			salsa.WithAfterSave(func(ctx context.Context, id any, ees []salsa.EncodedEvent, es []salsa.Event[state]) error {
+				act = append(act, id)
+				return nil
+			}))
+
+		as := map[string]*salsa.Aggregate[state]{"a": new(salsa.Aggregate[state]), "b": new(salsa.Aggregate[state])}
+		for _, a := range as {
+			for i := 0; i < 2; i++ {
+				_, err := a.Apply(&event{Amount: 10})
+				assertErrorExists(t, err, false)
+			}
+		}
+
+		err := sut.SaveAll(context.Background(), as)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, len(act), 2)
+	})
+
+	t.Run("should invoke the after hooks for every aggregate", func(t *testing.T) {
+		var act []any
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er),
+			salsa.WithAfterSave(func(ctx context.Context, id any, ees []salsa.EncodedEvent, es []salsa.Event[state]) error {
+				act = append(act, id)
+				return errHook
+			}))
+
+		as := map[string]*salsa.Aggregate[state]{"a": new(salsa.Aggregate[state]), "b": new(salsa.Aggregate[state])}
+		for _, a := range as {
+			_, err := a.Apply(&event{Amount: 10})
+			assertErrorExists(t, err, false)
+		}
+
+		err := sut.SaveAll(context.Background(), as)
+		if !errors.Is(err, errHook) {
+			t.Errorf("got %v, expected %v", err, errHook)
+		}
+
+		assertDeepEqual(t, len(act), 2)
+	})
+
+	t.Run("should not invoke the hooks if there are no events", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er),
+			salsa.WithBeforeSave(func(context.Context, any, []salsa.EncodedEvent, []salsa.Event[state]) error {
+				return errHook
+			}))
+
+		err := sut.Save(context.Background(), "id", new(salsa.Aggregate[state]))
+		assertErrorExists(t, err, false)
+	})
+}
@@ -0,0 +1,33 @@
+package salsa
+
+// sliceIterator is an event iterator over events that have already been read into memory
+type sliceIterator struct {
+	events []EncodedEvent
+	event  EncodedEvent
+}
+
+// Next advances the iterator, returning false if there are no more events
+func (i *sliceIterator) Next() bool {
+	if len(i.events) < 1 {
+		return false
+	}
+
+	i.event, i.events = i.events[0], i.events[1:]
+	return true
+}
+
+// Event returns the current event
+func (i *sliceIterator) Event() EncodedEvent {
+	return i.event
+}
+
+// Err returns the iteration error
+func (i *sliceIterator) Err() error {
+	return nil
+}
+
+// Close closes the iterator
+func (i *sliceIterator) Close() error {
+	i.events = nil
+	return nil
+}
@@ -0,0 +1,97 @@
+package salsa
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+type (
+	// Metadata represents event metadata
+	Metadata struct {
+		EventID       string
+		Timestamp     time.Time
+		CorrelationID string
+		CausationID   string
+		Headers       map[string]string
+	}
+
+	contextKey uint8
+)
+
+const (
+	correlationIDKey contextKey = iota + 1
+	causationIDKey
+	headersKey
+)
+
+// ContextWithCorrelationID returns a copy of the context with the specified correlation id
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// ContextWithCausationID returns a copy of the context with the specified causation id
+func ContextWithCausationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, causationIDKey, id)
+}
+
+// ContextWithHeaders returns a copy of the context with the specified headers
+// merged into any existing context headers
+func ContextWithHeaders(ctx context.Context, h map[string]string) context.Context {
+	m := map[string]string{}
+	if ch, ok := ctx.Value(headersKey).(map[string]string); ok {
+		for k, v := range ch {
+			m[k] = v
+		}
+	}
+
+	for k, v := range h {
+		m[k] = v
+	}
+
+	return context.WithValue(ctx, headersKey, m)
+}
+
+func newMetadata() Metadata {
+	return Metadata{
+		EventID:   newEventID(),
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// withContext returns a copy of the metadata with unset values populated from the context
+func (m Metadata) withContext(ctx context.Context) Metadata {
+	if id, ok := ctx.Value(correlationIDKey).(string); ok && m.CorrelationID == "" {
+		m.CorrelationID = id
+	}
+
+	if id, ok := ctx.Value(causationIDKey).(string); ok && m.CausationID == "" {
+		m.CausationID = id
+	}
+
+	if ch, ok := ctx.Value(headersKey).(map[string]string); ok {
+		h := make(map[string]string, len(ch)+len(m.Headers))
+		for k, v := range ch {
+			h[k] = v
+		}
+		for k, v := range m.Headers {
+			h[k] = v
+		}
+		m.Headers = h
+	}
+
+	return m
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,181 @@
+// Package outbox provides a relay that publishes events from a transactional outbox
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/stevecallear/salsa"
+)
+
+type (
+	// Relay publishes events written to the transactional outbox of an events DB
+	Relay[TI comparable] struct {
+		db        salsa.DB[TI]
+		publisher Publisher[TI]
+		opts      Options
+	}
+
+	// Options represents a set of relay options
+	Options struct {
+		BatchSize    int
+		PollInterval time.Duration
+		Retries      int
+		Backoff      time.Duration
+		MaxBackoff   time.Duration
+	}
+
+	// Publisher represents an outbox event publisher
+	Publisher[TI comparable] interface {
+		Publish(ctx context.Context, e salsa.StreamEvent[TI]) error
+	}
+
+	// PublisherFunc represents an outbox event publisher func
+	PublisherFunc[TI comparable] func(ctx context.Context, e salsa.StreamEvent[TI]) error
+)
+
+// New returns a new relay that publishes outbox events from the specified DB using the publisher.
+// The DB must implement salsa.OutboxDB.
+func New[TI comparable](db salsa.DB[TI], p Publisher[TI], optFns ...func(*Options)) *Relay[TI] {
+	o := Options{
+		BatchSize:    100,
+		PollInterval: time.Second,
+		Retries:      3,
+		Backoff:      100 * time.Millisecond,
+		MaxBackoff:   5 * time.Second,
+	}
+
+	for _, fn := range optFns {
+		fn(&o)
+	}
+
+	return &Relay[TI]{
+		db:        db,
+		publisher: p,
+		opts:      o,
+	}
+}
+
+// Run publishes all outbox events and then polls for new events until the context is cancelled
+// or an error occurs
+func (r *Relay[TI]) Run(ctx context.Context) error {
+	for {
+		if err := r.Drain(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.opts.PollInterval):
+		}
+	}
+}
+
+// Drain publishes all outbox events in position order, deleting each event once it has been published.
+// Failed publishes are retried with backoff. If an event cannot be published then subsequent events for the
+// same aggregate are not published, preserving the order per aggregate, and the first error is returned once
+// the remaining events in the batch have been published. salsa.ErrUnsupported is returned if the DB does
+// not implement salsa.OutboxDB.
+func (r *Relay[TI]) Drain(ctx context.Context) error {
+	odb, ok := r.db.(salsa.OutboxDB[TI])
+	if !ok {
+		return salsa.ErrUnsupported
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		es, err := odb.ReadOutbox(ctx, r.opts.BatchSize)
+		if err != nil || len(es) < 1 {
+			return err
+		}
+
+		ps, err := r.publishAll(ctx, es)
+		if len(ps) > 0 {
+			if derr := odb.DeleteOutbox(ctx, ps...); derr != nil {
+				return derr
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if r.opts.BatchSize < 1 || len(es) < r.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// publishAll publishes the events, returning the positions of the published events along with the first error
+func (r *Relay[TI]) publishAll(ctx context.Context, es []salsa.StreamEvent[TI]) ([]uint64, error) {
+	var ps []uint64
+	var perr error
+
+	blocked := map[TI]struct{}{}
+	for _, e := range es {
+		if _, ok := blocked[e.ID]; ok {
+			continue
+		}
+
+		if err := r.publish(ctx, e); err != nil {
+			if ctx.Err() != nil {
+				return ps, err
+			}
+
+			blocked[e.ID] = struct{}{}
+			if perr == nil {
+				perr = err
+			}
+			continue
+		}
+
+		ps = append(ps, e.Position)
+	}
+
+	return ps, perr
+}
+
+// publish publishes the event, retrying with backoff up to the configured number of retries
+func (r *Relay[TI]) publish(ctx context.Context, e salsa.StreamEvent[TI]) error {
+	d := r.opts.Backoff
+	for i := 0; ; i++ {
+		err := r.publisher.Publish(ctx, e)
+		if err == nil || i >= r.opts.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+
+		if d *= 2; d > r.opts.MaxBackoff {
+			d = r.opts.MaxBackoff
+		}
+	}
+}
+
+// Publish publishes the specified event
+func (fn PublisherFunc[TI]) Publish(ctx context.Context, e salsa.StreamEvent[TI]) error {
+	return fn(ctx, e)
+}
+
+// WithRetries configures the maximum number of times a failed publish is retried
+func WithRetries(n int) func(*Options) {
+	return func(o *Options) {
+		o.Retries = n
+	}
+}
+
+// WithBackoff configures the initial and maximum delay between publish retries
+func WithBackoff(initial, max time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.Backoff = initial
+		o.MaxBackoff = max
+	}
+}
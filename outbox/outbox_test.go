@@ -0,0 +1,214 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stevecallear/salsa"
+	"github.com/stevecallear/salsa/outbox"
+)
+
+func TestRelay_Drain(t *testing.T) {
+	errPublish := errors.New("error")
+
+	tests := []struct {
+		name      string
+		publish   func(attempts map[string]int, e salsa.StreamEvent[string]) error
+		published []string
+		remaining []string
+		err       error
+	}{
+		{
+			name: "should publish events in position order",
+			publish: func(map[string]int, salsa.StreamEvent[string]) error {
+				return nil
+			},
+			published: []string{"a:1", "b:1", "a:2", "b:2"},
+		},
+		{
+			name: "should retry failed publishes",
+			publish: func(attempts map[string]int, e salsa.StreamEvent[string]) error {
+				if attempts[key(e)] < 3 {
+					return errPublish
+				}
+				return nil
+			},
+			published: []string{"a:1", "b:1", "a:2", "b:2"},
+		},
+		{
+			name: "should preserve aggregate order if publishing fails",
+			publish: func(attempts map[string]int, e salsa.StreamEvent[string]) error {
+				if key(e) == "a:1" {
+					return errPublish
+				}
+				return nil
+			},
+			published: []string{"b:1"},
+			remaining: []string{"a:1", "a:2", "b:2"},
+			err:       errPublish,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := salsa.NewMemoryDB[string]()
+			str := salsa.NewStore[string](db,
+				salsa.WithResolver[state](salsa.EventResolverFunc[state](resolveEvent)),
+				salsa.WithOutbox[state]())
+
+			for _, id := range []string{"a", "b", "a", "b"} {
+				if _, err := str.Append(context.Background(), id, salsa.ExpectAny, &credited{Amount: 10}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			attempts := map[string]int{}
+			var published []string
+
+			sut := outbox.New[string](db, outbox.PublisherFunc[string](func(ctx context.Context, e salsa.StreamEvent[string]) error {
+				attempts[key(e)]++
+				if err := tt.publish(attempts, e); err != nil {
+					return err
+				}
+
+				published = append(published, key(e))
+				return nil
+			}), outbox.WithBackoff(time.Millisecond, time.Millisecond), func(o *outbox.Options) {
+				o.BatchSize = 3
+			})
+
+			err := sut.Drain(context.Background())
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, expected %v", err, tt.err)
+			}
+
+			assertDeepEqual(t, published, tt.published)
+
+			es, err := db.(salsa.OutboxDB[string]).ReadOutbox(context.Background(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var remaining []string
+			for _, e := range es {
+				remaining = append(remaining, key(e))
+			}
+
+			assertDeepEqual(t, remaining, tt.remaining)
+		})
+	}
+
+	t.Run("should return an error if the db does not support an outbox", func(t *testing.T) {
+		db := struct{ salsa.DB[string] }{salsa.NewMemoryDB[string]()}
+		sut := outbox.New[string](db, outbox.PublisherFunc[string](func(context.Context, salsa.StreamEvent[string]) error {
+			return nil
+		}))
+
+		err := sut.Drain(context.Background())
+		if !errors.Is(err, salsa.ErrUnsupported) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrUnsupported)
+		}
+	})
+}
+
+func TestRelay_Run(t *testing.T) {
+	db := salsa.NewMemoryDB[string]()
+	str := salsa.NewStore[string](db,
+		salsa.WithResolver[state](salsa.EventResolverFunc[state](resolveEvent)),
+		salsa.WithOutbox[state]())
+
+	published := make(chan string, 10)
+	sut := outbox.New[string](db, outbox.PublisherFunc[string](func(ctx context.Context, e salsa.StreamEvent[string]) error {
+		published <- key(e)
+		return nil
+	}), func(o *outbox.Options) {
+		o.PollInterval = time.Millisecond
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	go func() {
+		a := new(salsa.Aggregate[state])
+		for _, e := range []salsa.Event[state]{&credited{Amount: 10}, &debited{Amount: 5}} {
+			if _, err := a.Apply(e); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+
+		if err := str.Save(ctx, "a", a); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	err := sut.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	close(published)
+	var act []string
+	for k := range published {
+		act = append(act, k)
+	}
+
+	assertDeepEqual(t, act, []string{"a:1", "a:2"})
+}
+
+type (
+	state struct {
+		Balance int `json:"balance"`
+	}
+
+	credited struct {
+		Amount int `json:"amount"`
+	}
+
+	debited struct {
+		Amount int `json:"amount"`
+	}
+)
+
+func resolveEvent(eventType string) (salsa.Event[state], error) {
+	switch eventType {
+	case new(credited).Type():
+		return new(credited), nil
+	case new(debited).Type():
+		return new(debited), nil
+	default:
+		return nil, errors.New("invalid event type")
+	}
+}
+
+func (e *credited) Type() string {
+	return "credited"
+}
+
+func (e *credited) Apply(s state) (state, error) {
+	s.Balance += e.Amount
+	return s, nil
+}
+
+func (e *debited) Type() string {
+	return "debited"
+}
+
+func (e *debited) Apply(s state) (state, error) {
+	s.Balance -= e.Amount
+	return s, nil
+}
+
+func key(e salsa.StreamEvent[string]) string {
+	return fmt.Sprintf("%s:%d", e.ID, e.Version)
+}
+
+func assertDeepEqual(t *testing.T, act, exp interface{}) {
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
@@ -0,0 +1,151 @@
+package projection
+
+import (
+	"context"
+	"time"
+
+	"github.com/stevecallear/salsa"
+)
+
+type (
+	// Projection represents a read model projection of the global stream
+	Projection[TI comparable, TS any] struct {
+		name     string
+		store    *salsa.Store[TI, TS]
+		handlers []Handler[TI]
+		opts     Options
+	}
+
+	// Options represents a set of projection options
+	Options struct {
+		BatchSize    int
+		PollInterval time.Duration
+		Checkpoints  salsa.CheckpointStore
+		Reset        func(ctx context.Context) error
+	}
+
+	// Handler represents a projection event handler
+	Handler[TI comparable] interface {
+		Handle(ctx context.Context, m Metadata[TI], e any) error
+	}
+
+	// Metadata represents the metadata of a projected event
+	Metadata[TI comparable] struct {
+		ID       TI
+		Version  uint64
+		Position uint64
+		salsa.Metadata
+	}
+
+	handlerFunc[E any, TI comparable] func(ctx context.Context, m Metadata[TI], e E) error
+)
+
+// On returns a handler that invokes fn for events of type E
+func On[E any, TI comparable](fn func(ctx context.Context, m Metadata[TI], e E) error) Handler[TI] {
+	return handlerFunc[E, TI](fn)
+}
+
+// New returns a new projection with the specified name and handlers
+func New[TI comparable, TS any](s *salsa.Store[TI, TS], name string, hs []Handler[TI], optFns ...func(*Options)) *Projection[TI, TS] {
+	o := Options{
+		BatchSize:    100,
+		PollInterval: time.Second,
+		Checkpoints:  salsa.NewMemoryCheckpointStore(),
+		Reset: func(context.Context) error {
+			return nil
+		},
+	}
+
+	for _, fn := range optFns {
+		fn(&o)
+	}
+
+	return &Projection[TI, TS]{
+		name:     name,
+		store:    s,
+		handlers: hs,
+		opts:     o,
+	}
+}
+
+// Run projects all events after the last checkpoint and then polls for new events until
+// the context is cancelled or an error occurs
+func (p *Projection[TI, TS]) Run(ctx context.Context) error {
+	return p.subscription().Run(ctx)
+}
+
+// Rebuild resets the projection and projects all events from the start of the global stream.
+// The read model is reset before the checkpoint, so a failed reset leaves the projection unchanged.
+func (p *Projection[TI, TS]) Rebuild(ctx context.Context) error {
+	if err := p.opts.Reset(ctx); err != nil {
+		return err
+	}
+
+	if err := p.opts.Checkpoints.Save(ctx, p.name, 0); err != nil {
+		return err
+	}
+
+	return p.subscription().CatchUp(ctx)
+}
+
+func (p *Projection[TI, TS]) subscription() *salsa.Subscription[TI, TS] {
+	return salsa.NewSubscription(p.store, p.name, p.handle, func(o *salsa.SubscriptionOptions) {
+		o.BatchSize = p.opts.BatchSize
+		o.PollInterval = p.opts.PollInterval
+		o.Checkpoints = p.opts.Checkpoints
+	})
+}
+
+func (p *Projection[TI, TS]) handle(ctx context.Context, e salsa.RecordedEvent[TI, TS]) error {
+	m := Metadata[TI]{
+		ID:       e.ID,
+		Version:  e.Version,
+		Position: e.Position,
+		Metadata: e.Metadata,
+	}
+
+	for _, h := range p.handlers {
+		if err := h.Handle(ctx, m, e.Event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handle invokes the handler func if the event is of the expected type
+func (fn handlerFunc[E, TI]) Handle(ctx context.Context, m Metadata[TI], e any) error {
+	if te, ok := e.(E); ok {
+		return fn(ctx, m, te)
+	}
+
+	return nil
+}
+
+// WithBatchSize configures the projection to read the specified number of events at a time
+func WithBatchSize(n int) func(*Options) {
+	return func(o *Options) {
+		o.BatchSize = n
+	}
+}
+
+// WithPollInterval configures the projection to poll for new events at the specified interval
+func WithPollInterval(d time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.PollInterval = d
+	}
+}
+
+// WithCheckpointStore configures the projection to persist its position using the specified checkpoint store
+func WithCheckpointStore(cs salsa.CheckpointStore) func(*Options) {
+	return func(o *Options) {
+		o.Checkpoints = cs
+	}
+}
+
+// WithReset configures the func used to clear the read model when the projection is rebuilt
+func WithReset(fn func(ctx context.Context) error) func(*Options) {
+	return func(o *Options) {
+		o.Reset = fn
+	}
+}
@@ -0,0 +1,175 @@
+package projection_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stevecallear/salsa"
+	"github.com/stevecallear/salsa/projection"
+)
+
+func TestProjection(t *testing.T) {
+	str := salsa.NewMemoryStore[string](salsa.WithResolver[state](salsa.EventResolverFunc[state](resolveEvent)))
+
+	save := func(id string, es ...salsa.Event[state]) {
+		a := new(salsa.Aggregate[state])
+		for _, e := range es {
+			if _, err := a.Apply(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := str.Save(context.Background(), id, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	save("a", &credited{Amount: 10}, &debited{Amount: 5})
+	save("b", &credited{Amount: 20})
+
+	balances := map[string]int{}
+	var positions []uint64
+
+	sut := projection.New(str, "balances", []projection.Handler[string]{
+		projection.On[*credited](func(ctx context.Context, m projection.Metadata[string], e *credited) error {
+			balances[m.ID] += e.Amount
+			positions = append(positions, m.Position)
+			return nil
+		}),
+		projection.On[*debited](func(ctx context.Context, m projection.Metadata[string], e *debited) error {
+			balances[m.ID] -= e.Amount
+			positions = append(positions, m.Position)
+			return nil
+		}),
+	}, projection.WithReset(func(context.Context) error {
+		balances = map[string]int{}
+		positions = nil
+		return nil
+	}), projection.WithBatchSize(2), projection.WithPollInterval(time.Millisecond))
+
+	t.Run("should project events", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := sut.Run(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, expected %v", err, context.DeadlineExceeded)
+		}
+
+		assertDeepEqual(t, balances, map[string]int{"a": 5, "b": 20})
+		assertDeepEqual(t, positions, []uint64{1, 2, 3})
+	})
+
+	t.Run("should project events from the checkpoint", func(t *testing.T) {
+		save("c", &credited{Amount: 30})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := sut.Run(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, expected %v", err, context.DeadlineExceeded)
+		}
+
+		assertDeepEqual(t, balances, map[string]int{"a": 5, "b": 20, "c": 30})
+		assertDeepEqual(t, positions, []uint64{1, 2, 3, 4})
+	})
+
+	t.Run("should rebuild the projection", func(t *testing.T) {
+		balances["a"] = 1000
+
+		err := sut.Rebuild(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertDeepEqual(t, balances, map[string]int{"a": 5, "b": 20, "c": 30})
+		assertDeepEqual(t, positions, []uint64{1, 2, 3, 4})
+	})
+
+	t.Run("should return reset errors", func(t *testing.T) {
+		cs := salsa.NewMemoryCheckpointStore()
+		if err := cs.Save(context.Background(), "errors", 3); err != nil {
+			t.Fatal(err)
+		}
+
+		sut := projection.New(str, "errors", nil, projection.WithCheckpointStore(cs), projection.WithReset(func(context.Context) error {
+			return errors.New("error")
+		}))
+
+		if err := sut.Rebuild(context.Background()); err == nil {
+			t.Error("got nil, expected an error")
+		}
+
+		pos, err := cs.Load(context.Background(), "errors")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertDeepEqual(t, pos, uint64(3))
+	})
+
+	t.Run("should return handler errors", func(t *testing.T) {
+		sut := projection.New(str, "errors", []projection.Handler[string]{
+			projection.On[*debited](func(context.Context, projection.Metadata[string], *debited) error {
+				return errors.New("error")
+			}),
+		})
+
+		if err := sut.Rebuild(context.Background()); err == nil {
+			t.Error("got nil, expected an error")
+		}
+	})
+}
+
+type (
+	state struct {
+		Balance int `json:"balance"`
+	}
+
+	credited struct {
+		Amount int `json:"amount"`
+	}
+
+	debited struct {
+		Amount int `json:"amount"`
+	}
+)
+
+func resolveEvent(eventType string) (salsa.Event[state], error) {
+	switch eventType {
+	case new(credited).Type():
+		return new(credited), nil
+	case new(debited).Type():
+		return new(debited), nil
+	default:
+		return nil, errors.New("invalid event type")
+	}
+}
+
+func (e *credited) Type() string {
+	return "credited"
+}
+
+func (e *credited) Apply(s state) (state, error) {
+	s.Balance += e.Amount
+	return s, nil
+}
+
+func (e *debited) Type() string {
+	return "debited"
+}
+
+func (e *debited) Apply(s state) (state, error) {
+	s.Balance -= e.Amount
+	return s, nil
+}
+
+func assertDeepEqual(t *testing.T, act, exp interface{}) {
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
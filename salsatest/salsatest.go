@@ -0,0 +1,1073 @@
+// Package salsatest provides a conformance test suite for salsa DB implementations
+package salsatest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stevecallear/salsa"
+)
+
+// LargeStreamSize is the number of events written by the large stream test
+var LargeStreamSize = 1000
+
+// RunDBSuite runs the DB conformance test suite. The DB returned by fn is used for a
+// single test and may be shared between tests, as each test uses unique aggregate ids.
+func RunDBSuite(t *testing.T, fn func() salsa.DB[string]) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, salsa.DB[string])
+	}{
+		{name: "not found", fn: testNotFound},
+		{name: "empty write", fn: testEmptyWrite},
+		{name: "read write", fn: testReadWrite},
+		{name: "conflicts", fn: testConflicts},
+		{name: "rollback", fn: testRollback},
+		{name: "snapshots", fn: testSnapshots},
+		{name: "read events", fn: testReadEvents},
+		{name: "read all", fn: testReadAll},
+		{name: "purge states", fn: testPurgeStates},
+		{name: "concurrent writers", fn: testConcurrentWriters},
+		{name: "context cancellation", fn: testContextCancellation},
+		{name: "large streams", fn: testLargeStreams},
+		{name: "write multi", fn: testWriteMulti},
+		{name: "read iter", fn: testReadIter},
+		{name: "read at", fn: testReadAt},
+		{name: "read version", fn: testReadVersion},
+		{name: "outbox", fn: testOutbox},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, fn())
+		})
+	}
+}
+
+// RunSnapshotStoreSuite runs the snapshot store conformance test suite. The snapshot store returned by fn
+// is used for a single test and may be shared between tests, as each test uses unique aggregate ids.
+func RunSnapshotStoreSuite(t *testing.T, fn func() salsa.SnapshotStore[string]) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, salsa.SnapshotStore[string])
+	}{
+		{name: "read write states", fn: testReadWriteStates},
+		{name: "purge snapshot states", fn: testPurgeSnapshotStates},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, fn())
+		})
+	}
+}
+
+func testReadWriteStates(t *testing.T, ss salsa.SnapshotStore[string]) {
+	id := newID()
+
+	t.Run("should return a zero state if no snapshot exists", func(t *testing.T) {
+		act, err := ss.ReadState(context.Background(), id, 0)
+		assertNoError(t, err)
+		assertDeepEqual(t, act, salsa.EncodedState{})
+	})
+
+	for _, v := range []uint64{5, 3} {
+		err := ss.WriteState(context.Background(), id, newState(v))
+		assertNoError(t, err)
+	}
+
+	tests := []struct {
+		name    string
+		version uint64
+		exp     salsa.EncodedState
+	}{
+		{name: "should read the latest state", version: 0, exp: newState(5)},
+		{name: "should read the state at the version", version: 3, exp: newState(3)},
+		{name: "should read the state below the version", version: 4, exp: newState(3)},
+		{name: "should read the state after the version", version: 10, exp: newState(5)},
+		{name: "should return a zero state before the first snapshot", version: 2, exp: salsa.EncodedState{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			act, err := ss.ReadState(context.Background(), id, tt.version)
+			assertNoError(t, err)
+			assertDeepEqual(t, act, tt.exp)
+		})
+	}
+
+	t.Run("should replace a state with the same version", func(t *testing.T) {
+		exp := salsa.EncodedState{Version: 5, Schema: 2, Data: []byte(`{"replaced":true}`)}
+
+		err := ss.WriteState(context.Background(), id, exp)
+		assertNoError(t, err)
+
+		act, err := ss.ReadState(context.Background(), id, 0)
+		assertNoError(t, err)
+		assertDeepEqual(t, act, exp)
+	})
+
+	t.Run("should return an error if the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := ss.ReadState(ctx, id, 0)
+		assertErrorIs(t, err, context.Canceled)
+
+		err = ss.WriteState(ctx, id, newState(6))
+		assertErrorIs(t, err, context.Canceled)
+	})
+}
+
+func testPurgeSnapshotStates(t *testing.T, ss salsa.SnapshotStore[string]) {
+	id1, id2, id3 := newID(), newID(), newID()
+	for _, id := range []string{id1, id2, id3} {
+		err := ss.WriteState(context.Background(), id, newState(1))
+		assertNoError(t, err)
+	}
+
+	t.Run("should purge the states", func(t *testing.T) {
+		err := ss.PurgeStates(context.Background(), id1)
+		assertNoError(t, err)
+
+		act, err := ss.ReadState(context.Background(), id1, 0)
+		assertNoError(t, err)
+		assertDeepEqual(t, act, salsa.EncodedState{})
+
+		act, err = ss.ReadState(context.Background(), id2, 0)
+		assertNoError(t, err)
+		assertDeepEqual(t, act, newState(1))
+	})
+
+	t.Run("should purge all states", func(t *testing.T) {
+		err := ss.PurgeAllStates(context.Background())
+		assertNoError(t, err)
+
+		for _, id := range []string{id2, id3} {
+			act, err := ss.ReadState(context.Background(), id, 0)
+			assertNoError(t, err)
+			assertDeepEqual(t, act, salsa.EncodedState{})
+		}
+	})
+}
+
+func testNotFound(t *testing.T, db salsa.DB[string]) {
+	id := newID()
+
+	_, _, err := db.Read(context.Background(), id)
+	assertErrorIs(t, err, salsa.ErrNotFound)
+
+	_, err = db.ReadEvents(context.Background(), id, 1, 0)
+	assertErrorIs(t, err, salsa.ErrNotFound)
+}
+
+func testEmptyWrite(t *testing.T, db salsa.DB[string]) {
+	id := newID()
+
+	err := db.Write(context.Background(), id, func(salsa.DBTx) error {
+		return nil
+	})
+	assertNoError(t, err)
+
+	_, _, err = db.Read(context.Background(), id)
+	assertErrorIs(t, err, salsa.ErrNotFound)
+
+	_, err = db.ReadEvents(context.Background(), id, 1, 0)
+	assertErrorIs(t, err, salsa.ErrNotFound)
+
+	writeEvents(t, db, id, newEvent(1))
+
+	_, act, err := db.Read(context.Background(), id)
+	assertNoError(t, err)
+	assertEventsEqual(t, act, []salsa.EncodedEvent{newEvent(1)})
+}
+
+func testReadWrite(t *testing.T, db salsa.DB[string]) {
+	id := newID()
+	ts := time.Date(2022, 4, 1, 12, 30, 15, 500000, time.UTC)
+
+	exp := []salsa.EncodedEvent{
+		{
+			Type:    "created",
+			Version: 1,
+			Data:    []byte(`{"id":1}`),
+			Metadata: salsa.Metadata{
+				EventID:   "event1",
+				Timestamp: ts,
+			},
+		},
+		{
+			Type:    "updated",
+			Version: 2,
+			Schema:  2,
+			Data:    []byte(`{"value":"a"}`),
+			Metadata: salsa.Metadata{
+				EventID:       "event2",
+				Timestamp:     ts.Add(time.Second),
+				CorrelationID: "correlationid",
+				CausationID:   "causationid",
+				Headers:       map[string]string{"key": "value"},
+			},
+		},
+	}
+
+	writeEvents(t, db, id, exp...)
+
+	s, act, err := db.Read(context.Background(), id)
+	assertNoError(t, err)
+	assertDeepEqual(t, s, salsa.EncodedState{})
+	assertEventsEqual(t, act, exp)
+}
+
+func testConflicts(t *testing.T, db salsa.DB[string]) {
+	id := newID()
+	writeEvents(t, db, id, newEvents(1, 2)...)
+
+	tests := []struct {
+		name     string
+		version  uint64
+		expected uint64
+	}{
+		{name: "existing version", version: 2, expected: 1},
+		{name: "stale version", version: 1, expected: 0},
+		{name: "future version", version: 4, expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+				return tx.Event(newEvent(tt.version))
+			})
+
+			var cerr *salsa.ConflictError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("got %v, expected a conflict error", err)
+			}
+
+			assertErrorIs(t, err, salsa.ErrConflict)
+			assertDeepEqual(t, *cerr, salsa.ConflictError{ID: id, Expected: tt.expected, Actual: 2})
+		})
+	}
+
+	_, act, err := db.Read(context.Background(), id)
+	assertNoError(t, err)
+	assertEventsEqual(t, act, newEvents(1, 2))
+}
+
+func testRollback(t *testing.T, db salsa.DB[string]) {
+	id := newID()
+	writeEvents(t, db, id, newEvent(1))
+
+	exp := errors.New("error")
+	err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+		if err := tx.Event(newEvent(2)); err != nil {
+			return err
+		}
+		return exp
+	})
+	assertErrorIs(t, err, exp)
+
+	_, act, err := db.Read(context.Background(), id)
+	assertNoError(t, err)
+	assertEventsEqual(t, act, newEvents(1, 1))
+}
+
+func testSnapshots(t *testing.T, db salsa.DB[string]) {
+	id := newID()
+	exp := salsa.EncodedState{Version: 3, Schema: 1, Data: []byte(`{"state":3}`)}
+
+	err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+		for _, e := range newEvents(1, 3) {
+			if err := tx.Event(e); err != nil {
+				return err
+			}
+		}
+		return tx.State(exp)
+	})
+	assertNoError(t, err)
+
+	t.Run("should read the state", func(t *testing.T) {
+		s, act, err := db.Read(context.Background(), id)
+		assertNoError(t, err)
+		assertDeepEqual(t, s, exp)
+		assertEventsEqual(t, act, nil)
+	})
+
+	writeEvents(t, db, id, newEvents(4, 5)...)
+
+	t.Run("should read the state and events", func(t *testing.T) {
+		s, act, err := db.Read(context.Background(), id)
+		assertNoError(t, err)
+		assertDeepEqual(t, s, exp)
+		assertEventsEqual(t, act, newEvents(4, 5))
+	})
+
+	t.Run("should read the latest state", func(t *testing.T) {
+		exp := salsa.EncodedState{Version: 5, Data: []byte(`{"state":5}`)}
+
+		err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+			return tx.State(exp)
+		})
+		assertNoError(t, err)
+
+		s, act, err := db.Read(context.Background(), id)
+		assertNoError(t, err)
+		assertDeepEqual(t, s, exp)
+		assertEventsEqual(t, act, nil)
+	})
+}
+
+func testReadEvents(t *testing.T, db salsa.DB[string]) {
+	id := newID()
+
+	err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+		for _, e := range newEvents(1, 5) {
+			if err := tx.Event(e); err != nil {
+				return err
+			}
+		}
+		return tx.State(salsa.EncodedState{Version: 5, Data: []byte(`{}`)})
+	})
+	assertNoError(t, err)
+
+	tests := []struct {
+		name     string
+		from, to uint64
+		exp      []salsa.EncodedEvent
+	}{
+		{name: "should read all events", from: 1, to: 0, exp: newEvents(1, 5)},
+		{name: "should read from the version", from: 3, to: 0, exp: newEvents(3, 5)},
+		{name: "should read the version range", from: 2, to: 4, exp: newEvents(2, 4)},
+		{name: "should read a single version", from: 4, to: 4, exp: newEvents(4, 4)},
+		{name: "should return empty ranges", from: 6, to: 0, exp: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			act, err := db.ReadEvents(context.Background(), id, tt.from, tt.to)
+			assertNoError(t, err)
+			assertEventsEqual(t, act, tt.exp)
+		})
+	}
+}
+
+func testReadAll(t *testing.T, db salsa.DB[string]) {
+	ids := []string{newID(), newID()}
+
+	var exp []salsa.StreamEvent[string]
+	for v := uint64(1); v <= 3; v++ {
+		for _, id := range ids {
+			e := newEvent(v)
+			writeEvents(t, db, id, e)
+			exp = append(exp, salsa.StreamEvent[string]{ID: id, EncodedEvent: e})
+		}
+	}
+
+	all, err := db.ReadAll(context.Background(), 0, 0)
+	assertNoError(t, err)
+
+	var act []salsa.StreamEvent[string]
+	var prev uint64
+	for _, e := range all {
+		if e.Position <= prev {
+			t.Fatalf("got position %d, expected greater than %d", e.Position, prev)
+		}
+		prev = e.Position
+
+		if e.ID == ids[0] || e.ID == ids[1] {
+			act = append(act, e)
+		}
+	}
+
+	if len(act) != len(exp) {
+		t.Fatalf("got %d events, expected %d", len(act), len(exp))
+	}
+
+	for i := range act {
+		assertDeepEqual(t, act[i].ID, exp[i].ID)
+		assertEventsEqual(t, []salsa.EncodedEvent{act[i].EncodedEvent}, []salsa.EncodedEvent{exp[i].EncodedEvent})
+	}
+
+	t.Run("should read from the position", func(t *testing.T) {
+		res, err := db.ReadAll(context.Background(), act[2].Position, 2)
+		assertNoError(t, err)
+
+		if len(res) != 2 {
+			t.Fatalf("got %d events, expected 2", len(res))
+		}
+
+		assertDeepEqual(t, res[0].Position, act[2].Position)
+		assertDeepEqual(t, res[0].ID, act[2].ID)
+	})
+
+	t.Run("should set the event position", func(t *testing.T) {
+		_, es, err := db.Read(context.Background(), ids[0])
+		assertNoError(t, err)
+
+		for i, e := range es {
+			assertDeepEqual(t, e.Position, act[i*2].Position)
+		}
+	})
+}
+
+func testPurgeStates(t *testing.T, db salsa.DB[string]) {
+	ids := []string{newID(), newID()}
+
+	for _, id := range ids {
+		err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+			for _, e := range newEvents(1, 2) {
+				if err := tx.Event(e); err != nil {
+					return err
+				}
+			}
+			return tx.State(salsa.EncodedState{Version: 2, Data: []byte(`{}`)})
+		})
+		assertNoError(t, err)
+	}
+
+	assertState := func(t *testing.T, id string, exp salsa.EncodedState, expEvents []salsa.EncodedEvent) {
+		s, act, err := db.Read(context.Background(), id)
+		assertNoError(t, err)
+		assertDeepEqual(t, s, exp)
+		assertEventsEqual(t, act, expEvents)
+	}
+
+	t.Run("should purge aggregate states", func(t *testing.T) {
+		err := db.PurgeStates(context.Background(), ids[0])
+		assertNoError(t, err)
+
+		assertState(t, ids[0], salsa.EncodedState{}, newEvents(1, 2))
+		assertState(t, ids[1], salsa.EncodedState{Version: 2, Data: []byte(`{}`)}, nil)
+	})
+
+	t.Run("should purge all states", func(t *testing.T) {
+		err := db.PurgeAllStates(context.Background())
+		assertNoError(t, err)
+
+		assertState(t, ids[0], salsa.EncodedState{}, newEvents(1, 2))
+		assertState(t, ids[1], salsa.EncodedState{}, newEvents(1, 2))
+	})
+
+	t.Run("should not return an error if the aggregate does not exist", func(t *testing.T) {
+		err := db.PurgeStates(context.Background(), newID())
+		assertNoError(t, err)
+	})
+}
+
+func testConcurrentWriters(t *testing.T, db salsa.DB[string]) {
+	const n = 10
+
+	t.Run("should allow a single writer per version", func(t *testing.T) {
+		id := newID()
+
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+					return tx.Event(newEvent(1))
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		var ok int
+		for _, err := range errs {
+			if err == nil {
+				ok++
+				continue
+			}
+			assertErrorIs(t, err, salsa.ErrConflict)
+		}
+
+		assertDeepEqual(t, ok, 1)
+
+		_, act, err := db.Read(context.Background(), id)
+		assertNoError(t, err)
+		assertEventsEqual(t, act, newEvents(1, 1))
+	})
+
+	t.Run("should allow concurrent writers for different aggregates", func(t *testing.T) {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = newID()
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = db.Write(context.Background(), ids[i], func(tx salsa.DBTx) error {
+					return tx.Event(newEvent(1))
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			assertNoError(t, err)
+		}
+
+		all, err := db.ReadAll(context.Background(), 0, 0)
+		assertNoError(t, err)
+
+		ps := map[uint64]bool{}
+		for _, e := range all {
+			if ps[e.Position] {
+				t.Errorf("got duplicate position %d", e.Position)
+			}
+			ps[e.Position] = true
+		}
+
+		for _, id := range ids {
+			_, act, err := db.Read(context.Background(), id)
+			assertNoError(t, err)
+			assertEventsEqual(t, act, newEvents(1, 1))
+		}
+	})
+}
+
+func testContextCancellation(t *testing.T, db salsa.DB[string]) {
+	id := newID()
+	writeEvents(t, db, id, newEvent(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("should not write", func(t *testing.T) {
+		err := db.Write(ctx, id, func(tx salsa.DBTx) error {
+			return tx.Event(newEvent(2))
+		})
+		assertErrorIs(t, err, context.Canceled)
+
+		_, act, err := db.Read(context.Background(), id)
+		assertNoError(t, err)
+		assertEventsEqual(t, act, newEvents(1, 1))
+	})
+
+	t.Run("should not read", func(t *testing.T) {
+		_, _, err := db.Read(ctx, id)
+		assertErrorIs(t, err, context.Canceled)
+
+		_, err = db.ReadEvents(ctx, id, 1, 0)
+		assertErrorIs(t, err, context.Canceled)
+
+		_, err = db.ReadAll(ctx, 0, 0)
+		assertErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should not purge", func(t *testing.T) {
+		err := db.PurgeStates(ctx, id)
+		assertErrorIs(t, err, context.Canceled)
+
+		err = db.PurgeAllStates(ctx)
+		assertErrorIs(t, err, context.Canceled)
+	})
+}
+
+func testLargeStreams(t *testing.T, db salsa.DB[string]) {
+	const batchSize = 25
+
+	id := newID()
+	n := uint64(LargeStreamSize)
+	sv := n / 2
+
+	for v := uint64(1); v <= n; v += batchSize {
+		to := v + batchSize - 1
+		if to > n {
+			to = n
+		}
+
+		err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+			for _, e := range newEvents(v, to) {
+				if err := tx.Event(e); err != nil {
+					return err
+				}
+
+				if e.Version == sv {
+					if err := tx.State(salsa.EncodedState{Version: sv, Data: []byte(`{}`)}); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		assertNoError(t, err)
+	}
+
+	t.Run("should read the state and events", func(t *testing.T) {
+		s, act, err := db.Read(context.Background(), id)
+		assertNoError(t, err)
+		assertDeepEqual(t, s.Version, sv)
+		assertEventsEqual(t, act, newEvents(sv+1, n))
+	})
+
+	t.Run("should read all events", func(t *testing.T) {
+		act, err := db.ReadEvents(context.Background(), id, 1, 0)
+		assertNoError(t, err)
+		assertEventsEqual(t, act, newEvents(1, n))
+	})
+
+	t.Run("should iterate the state and events", func(t *testing.T) {
+		idb, ok := db.(salsa.IterDB[string])
+		if !ok {
+			t.Skip("iterator reads are not supported")
+		}
+
+		s, act := readIter(t, idb, id)
+		assertDeepEqual(t, s.Version, sv)
+		assertEventsEqual(t, act, newEvents(sv+1, n))
+	})
+}
+
+func testWriteMulti(t *testing.T, db salsa.DB[string]) {
+	mdb, ok := db.(salsa.MultiDB[string])
+	if !ok {
+		t.Skip("multi-aggregate writes are not supported")
+	}
+
+	id1, id2 := newID(), newID()
+	writeEvents(t, db, id2, newEvent(1))
+
+	t.Run("should write all aggregates", func(t *testing.T) {
+		err := mdb.WriteMulti(context.Background(), []string{id1, id2}, func(id string, tx salsa.DBTx) error {
+			if id == id1 {
+				return tx.Event(newEvent(1))
+			}
+
+			for _, e := range newEvents(2, 3) {
+				if err := tx.Event(e); err != nil {
+					return err
+				}
+			}
+			return tx.State(salsa.EncodedState{Version: 3, Data: []byte(`{"state":3}`)})
+		})
+		assertNoError(t, err)
+
+		_, act, err := db.Read(context.Background(), id1)
+		assertNoError(t, err)
+		assertEventsEqual(t, act, newEvents(1, 1))
+
+		state, act, err := db.Read(context.Background(), id2)
+		assertNoError(t, err)
+		assertDeepEqual(t, state.Version, uint64(3))
+		assertEventsEqual(t, act, nil)
+
+		act, err = db.ReadEvents(context.Background(), id2, 1, 0)
+		assertNoError(t, err)
+		assertEventsEqual(t, act, newEvents(1, 3))
+	})
+
+	t.Run("should not write any aggregates if a conflict occurs", func(t *testing.T) {
+		err := mdb.WriteMulti(context.Background(), []string{id1, id2}, func(id string, tx salsa.DBTx) error {
+			return tx.Event(newEvent(2))
+		})
+
+		var cerr *salsa.ConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: id2, Expected: 1, Actual: 3})
+
+		act, err := db.ReadEvents(context.Background(), id1, 1, 0)
+		assertNoError(t, err)
+		assertEventsEqual(t, act, newEvents(1, 1))
+	})
+
+	t.Run("should not write any aggregates if an error occurs", func(t *testing.T) {
+		exp := errors.New("error")
+		err := mdb.WriteMulti(context.Background(), []string{id1, id2}, func(id string, tx salsa.DBTx) error {
+			if id == id2 {
+				return exp
+			}
+			return tx.Event(newEvent(2))
+		})
+		assertErrorIs(t, err, exp)
+
+		act, err := db.ReadEvents(context.Background(), id1, 1, 0)
+		assertNoError(t, err)
+		assertEventsEqual(t, act, newEvents(1, 1))
+	})
+}
+
+func testReadIter(t *testing.T, db salsa.DB[string]) {
+	idb, ok := db.(salsa.IterDB[string])
+	if !ok {
+		t.Skip("iterator reads are not supported")
+	}
+
+	t.Run("should return an empty iterator if the aggregate does not exist", func(t *testing.T) {
+		s, act := readIter(t, idb, newID())
+		assertDeepEqual(t, s, salsa.EncodedState{})
+		assertEventsEqual(t, act, nil)
+	})
+
+	id := newID()
+	writeEvents(t, db, id, newEvents(1, 3)...)
+
+	t.Run("should iterate the events", func(t *testing.T) {
+		s, act := readIter(t, idb, id)
+		assertDeepEqual(t, s, salsa.EncodedState{})
+		assertEventsEqual(t, act, newEvents(1, 3))
+	})
+
+	exp := salsa.EncodedState{Version: 3, Schema: 1, Data: []byte(`{"state":3}`)}
+	err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+		return tx.State(exp)
+	})
+	assertNoError(t, err)
+
+	t.Run("should iterate the state", func(t *testing.T) {
+		s, act := readIter(t, idb, id)
+		assertDeepEqual(t, s, exp)
+		assertEventsEqual(t, act, nil)
+	})
+
+	writeEvents(t, db, id, newEvents(4, 5)...)
+
+	t.Run("should iterate the state and events", func(t *testing.T) {
+		s, act := readIter(t, idb, id)
+		assertDeepEqual(t, s, exp)
+		assertEventsEqual(t, act, newEvents(4, 5))
+	})
+
+	t.Run("should stop iterating if the iterator is closed", func(t *testing.T) {
+		_, it, err := idb.ReadIter(context.Background(), id)
+		assertNoError(t, err)
+		assertNoError(t, it.Close())
+		assertDeepEqual(t, it.Next(), false)
+	})
+
+	t.Run("should return an error if the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, _, err := idb.ReadIter(ctx, id)
+		assertErrorIs(t, err, context.Canceled)
+	})
+}
+
+func testReadAt(t *testing.T, db salsa.DB[string]) {
+	vdb, ok := db.(salsa.VersionDB[string])
+	if !ok {
+		t.Skip("version reads are not supported")
+	}
+
+	t.Run("should return an error if the aggregate does not exist", func(t *testing.T) {
+		_, _, err := vdb.ReadAt(context.Background(), newID(), 1)
+		assertErrorIs(t, err, salsa.ErrNotFound)
+	})
+
+	id := newID()
+	for _, r := range [][2]uint64{{1, 3}, {4, 5}} {
+		from, v := r[0], r[1]
+		err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+			for _, e := range newEvents(from, v) {
+				if err := tx.Event(e); err != nil {
+					return err
+				}
+			}
+			return tx.State(salsa.EncodedState{Version: v, Data: []byte(fmt.Sprintf(`{"state":%d}`, v))})
+		})
+		assertNoError(t, err)
+	}
+	writeEvents(t, db, id, newEvent(6))
+
+	tests := []struct {
+		name    string
+		version uint64
+		latest  uint64
+	}{
+		{name: "before the first snapshot", version: 2, latest: 2},
+		{name: "at a snapshot", version: 3, latest: 3},
+		{name: "between snapshots", version: 4, latest: 4},
+		{name: "at the latest snapshot", version: 5, latest: 5},
+		{name: "at the latest event", version: 6, latest: 6},
+		{name: "after the latest event", version: 10, latest: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run("should read "+tt.name, func(t *testing.T) {
+			s, act, err := vdb.ReadAt(context.Background(), id, tt.version)
+			assertNoError(t, err)
+
+			// backends may return any snapshot at or below the version
+			if s.Version > tt.version {
+				t.Fatalf("got state version %d, expected %d or lower", s.Version, tt.version)
+			}
+
+			if s.Version > 0 {
+				assertDeepEqual(t, s.Data, []byte(fmt.Sprintf(`{"state":%d}`, s.Version)))
+			}
+
+			assertEventsEqual(t, act, newEvents(s.Version+1, tt.latest))
+		})
+	}
+}
+
+func testReadVersion(t *testing.T, db salsa.DB[string]) {
+	vdb, ok := db.(salsa.VersionDB[string])
+	if !ok {
+		t.Skip("version reads are not supported")
+	}
+
+	t.Run("should return an error if the aggregate does not exist", func(t *testing.T) {
+		_, err := vdb.ReadVersion(context.Background(), newID())
+		assertErrorIs(t, err, salsa.ErrNotFound)
+	})
+
+	t.Run("should read the current version", func(t *testing.T) {
+		id := newID()
+		err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+			for _, e := range newEvents(1, 3) {
+				if err := tx.Event(e); err != nil {
+					return err
+				}
+			}
+			return tx.State(salsa.EncodedState{Version: 3, Data: []byte(`{"state":3}`)})
+		})
+		assertNoError(t, err)
+
+		act, err := vdb.ReadVersion(context.Background(), id)
+		assertNoError(t, err)
+		assertDeepEqual(t, act, uint64(3))
+	})
+}
+
+func testOutbox(t *testing.T, db salsa.DB[string]) {
+	odb, ok := db.(salsa.OutboxDB[string])
+	if !ok {
+		t.Skip("transactional outbox is not supported")
+	}
+
+	id1, id2 := newID(), newID()
+
+	// outbox events are global, so results are filtered to the test ids
+	readOutbox := func(t *testing.T) []salsa.StreamEvent[string] {
+		t.Helper()
+
+		es, err := odb.ReadOutbox(context.Background(), 0)
+		assertNoError(t, err)
+
+		var res []salsa.StreamEvent[string]
+		for _, e := range es {
+			if e.ID == id1 || e.ID == id2 {
+				res = append(res, e)
+			}
+		}
+		return res
+	}
+
+	writeOutbox := func(t *testing.T, id string, es ...salsa.EncodedEvent) {
+		t.Helper()
+
+		err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+			for _, e := range es {
+				if err := tx.Event(e); err != nil {
+					return err
+				}
+
+				if err := tx.(salsa.OutboxTx).Outbox(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		assertNoError(t, err)
+	}
+
+	writeOutbox(t, id1, newEvents(1, 2)...)
+	writeOutbox(t, id2, newEvent(1))
+	writeEvents(t, db, id1, newEvent(3))
+
+	t.Run("should read outbox events in position order", func(t *testing.T) {
+		act := readOutbox(t)
+		if len(act) != 3 {
+			t.Fatalf("got %d events, expected 3", len(act))
+		}
+
+		all, err := db.ReadEvents(context.Background(), id1, 1, 2)
+		assertNoError(t, err)
+
+		assertDeepEqual(t, []string{act[0].ID, act[1].ID, act[2].ID}, []string{id1, id1, id2})
+		assertEventsEqual(t, []salsa.EncodedEvent{act[0].EncodedEvent, act[1].EncodedEvent}, newEvents(1, 2))
+		assertEventsEqual(t, []salsa.EncodedEvent{act[2].EncodedEvent}, newEvents(1, 1))
+		assertDeepEqual(t, []uint64{act[0].Position, act[1].Position}, []uint64{all[0].Position, all[1].Position})
+
+		if act[2].Position <= act[1].Position {
+			t.Errorf("got position %d, expected greater than %d", act[2].Position, act[1].Position)
+		}
+	})
+
+	t.Run("should limit the outbox events", func(t *testing.T) {
+		act, err := odb.ReadOutbox(context.Background(), 2)
+		assertNoError(t, err)
+		assertDeepEqual(t, len(act), 2)
+	})
+
+	t.Run("should return an error if the event was not written in the transaction", func(t *testing.T) {
+		err := db.Write(context.Background(), id2, func(tx salsa.DBTx) error {
+			return tx.(salsa.OutboxTx).Outbox(newEvent(2))
+		})
+		if err == nil {
+			t.Error("got nil, expected an error")
+		}
+	})
+
+	t.Run("should not write outbox events if the transaction fails", func(t *testing.T) {
+		exp := errors.New("error")
+		err := db.Write(context.Background(), id2, func(tx salsa.DBTx) error {
+			if err := tx.Event(newEvent(2)); err != nil {
+				return err
+			}
+
+			if err := tx.(salsa.OutboxTx).Outbox(newEvent(2)); err != nil {
+				return err
+			}
+			return exp
+		})
+		assertErrorIs(t, err, exp)
+		assertDeepEqual(t, len(readOutbox(t)), 3)
+	})
+
+	t.Run("should delete outbox events", func(t *testing.T) {
+		es := readOutbox(t)
+
+		err := odb.DeleteOutbox(context.Background(), es[0].Position, es[1].Position)
+		assertNoError(t, err)
+
+		act := readOutbox(t)
+		assertDeepEqual(t, act, es[2:])
+
+		err = odb.DeleteOutbox(context.Background(), es[2].Position)
+		assertNoError(t, err)
+		assertDeepEqual(t, len(readOutbox(t)), 0)
+	})
+}
+
+func readIter(t *testing.T, db salsa.IterDB[string], id string) (salsa.EncodedState, []salsa.EncodedEvent) {
+	t.Helper()
+
+	s, it, err := db.ReadIter(context.Background(), id)
+	assertNoError(t, err)
+	defer it.Close()
+
+	var events []salsa.EncodedEvent
+	for it.Next() {
+		events = append(events, it.Event())
+	}
+	assertNoError(t, it.Err())
+
+	return s, events
+}
+
+func writeEvents(t *testing.T, db salsa.DB[string], id string, es ...salsa.EncodedEvent) {
+	t.Helper()
+
+	err := db.Write(context.Background(), id, func(tx salsa.DBTx) error {
+		for _, e := range es {
+			if err := tx.Event(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newEvents(from, to uint64) []salsa.EncodedEvent {
+	var es []salsa.EncodedEvent
+	for v := from; v <= to; v++ {
+		es = append(es, newEvent(v))
+	}
+	return es
+}
+
+func newEvent(v uint64) salsa.EncodedEvent {
+	return salsa.EncodedEvent{
+		Type:    "event",
+		Version: v,
+		Data:    []byte(fmt.Sprintf(`{"version":%d}`, v)),
+		Metadata: salsa.Metadata{
+			EventID:   fmt.Sprintf("event%d", v),
+			Timestamp: time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func newState(v uint64) salsa.EncodedState {
+	return salsa.EncodedState{
+		Version: v,
+		Schema:  1,
+		Data:    []byte(fmt.Sprintf(`{"state":%d}`, v)),
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// assertEventsEqual compares events excluding the backend assigned position
+func assertEventsEqual(t *testing.T, act, exp []salsa.EncodedEvent) {
+	t.Helper()
+
+	if len(act) != len(exp) {
+		t.Fatalf("got %d events, expected %d", len(act), len(exp))
+	}
+
+	for i := range act {
+		if act[i].Position == 0 {
+			t.Errorf("got position 0, expected a global position")
+		}
+
+		a, e := act[i], exp[i]
+		if !a.Metadata.Timestamp.Equal(e.Metadata.Timestamp) {
+			t.Errorf("got %v, expected %v", a.Metadata.Timestamp, e.Metadata.Timestamp)
+		}
+
+		a.Position, e.Position = 0, 0
+		a.Metadata.Timestamp, e.Metadata.Timestamp = time.Time{}, time.Time{}
+		assertDeepEqual(t, a, e)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("got %v, expected nil", err)
+	}
+}
+
+func assertErrorIs(t *testing.T, act, exp error) {
+	t.Helper()
+
+	if !errors.Is(act, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
+
+func assertDeepEqual(t *testing.T, act, exp interface{}) {
+	t.Helper()
+
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
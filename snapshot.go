@@ -0,0 +1,126 @@
+package salsa
+
+import "time"
+
+type (
+	// SnapshotPolicy represents a policy that determines whether a state snapshot should be written
+	SnapshotPolicy interface {
+		ShouldSnapshot(i SnapshotInfo) bool
+	}
+
+	// SnapshotPolicyFunc represents a snapshot policy func
+	SnapshotPolicyFunc func(i SnapshotInfo) bool
+
+	// SnapshotInfo represents the aggregate information used to determine whether a snapshot should be written
+	SnapshotInfo struct {
+		// Versions contains the aggregate versions, including the events being saved
+		Versions Versions
+
+		// EventTypes contains the types of the events being saved
+		EventTypes []string
+
+		// Elapsed is the time since the first event after the latest snapshot
+		Elapsed time.Duration
+	}
+)
+
+// ShouldSnapshot returns true if a snapshot should be written
+func (fn SnapshotPolicyFunc) ShouldSnapshot(i SnapshotInfo) bool {
+	return fn(i)
+}
+
+// SnapshotEvery returns a snapshot policy that snapshots once n events have been applied since the latest snapshot
+func SnapshotEvery(n int) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(i SnapshotInfo) bool {
+		d := i.Versions.Current - i.Versions.State
+		return d > 0 && d >= uint64(n)
+	})
+}
+
+// SnapshotOnEvents returns a snapshot policy that snapshots when any of the specified event types are saved
+func SnapshotOnEvents(eventTypes ...string) SnapshotPolicy {
+	m := make(map[string]struct{}, len(eventTypes))
+	for _, et := range eventTypes {
+		m[et] = struct{}{}
+	}
+
+	return SnapshotPolicyFunc(func(i SnapshotInfo) bool {
+		for _, et := range i.EventTypes {
+			if _, ok := m[et]; ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// SnapshotAfter returns a snapshot policy that snapshots once the first event after the latest snapshot
+// is older than the specified duration
+func SnapshotAfter(d time.Duration) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(i SnapshotInfo) bool {
+		return i.Versions.Current > i.Versions.State && i.Elapsed >= d
+	})
+}
+
+// SnapshotNever returns a snapshot policy that never snapshots
+func SnapshotNever() SnapshotPolicy {
+	return SnapshotPolicyFunc(func(SnapshotInfo) bool {
+		return false
+	})
+}
+
+// SnapshotAny returns a snapshot policy that snapshots if any of the specified policies snapshot
+func SnapshotAny(ps ...SnapshotPolicy) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(i SnapshotInfo) bool {
+		for _, p := range ps {
+			if p.ShouldSnapshot(i) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithSnapshotPolicy configures the store to use the specified snapshot policy in place of the snapshot rate
+func WithSnapshotPolicy[T any](p SnapshotPolicy) func(*Options[T]) {
+	return func(o *Options[T]) {
+		o.SnapshotPolicy = p
+	}
+}
+
+// WithAsyncSnapshots configures the store to write snapshots in the background after events are saved,
+// with up to the specified number of concurrent writes. State values are encoded in the background, so
+// events must not modify existing state in place. Store.Flush should be called before shutdown.
+func WithAsyncSnapshots[T any](concurrency int) func(*Options[T]) {
+	return func(o *Options[T]) {
+		o.AsyncSnapshots = concurrency
+	}
+}
+
+// snapshotRate returns a snapshot policy that snapshots once more than rate events have been applied
+// since the latest snapshot
+func snapshotRate(rate int) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(i SnapshotInfo) bool {
+		return i.Versions.Current-i.Versions.State > uint64(rate)
+	})
+}
+
+// newSnapshotInfo returns the snapshot info for the aggregate
+func newSnapshotInfo[T any](a *Aggregate[T], now time.Time) SnapshotInfo {
+	i := SnapshotInfo{Versions: a.Versions()}
+
+	for _, e := range a.Events() {
+		i.EventTypes = append(i.EventTypes, e.Type())
+	}
+
+	// metadata contains all events since the latest snapshot, although events applied
+	// using NewAggregate do not have a timestamp
+	for _, m := range a.Metadata() {
+		if !m.Timestamp.IsZero() {
+			i.Elapsed = now.Sub(m.Timestamp)
+			break
+		}
+	}
+
+	return i
+}
@@ -0,0 +1,151 @@
+package salsa_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stevecallear/salsa"
+)
+
+func TestSnapshotPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy salsa.SnapshotPolicy
+		info   salsa.SnapshotInfo
+		exp    bool
+	}{
+		{
+			name:   "every should not snapshot before n events",
+			policy: salsa.SnapshotEvery(3),
+			info:   salsa.SnapshotInfo{Versions: salsa.Versions{State: 2, Initial: 3, Current: 4}},
+			exp:    false,
+		},
+		{
+			name:   "every should snapshot after n events",
+			policy: salsa.SnapshotEvery(3),
+			info:   salsa.SnapshotInfo{Versions: salsa.Versions{State: 2, Initial: 3, Current: 5}},
+			exp:    true,
+		},
+		{
+			name:   "every should not snapshot without events",
+			policy: salsa.SnapshotEvery(0),
+			info:   salsa.SnapshotInfo{Versions: salsa.Versions{State: 2, Initial: 2, Current: 2}},
+			exp:    false,
+		},
+		{
+			name:   "on events should not snapshot for other event types",
+			policy: salsa.SnapshotOnEvents("closed", "reopened"),
+			info:   salsa.SnapshotInfo{EventTypes: []string{"credited", "debited"}},
+			exp:    false,
+		},
+		{
+			name:   "on events should snapshot for matching event types",
+			policy: salsa.SnapshotOnEvents("closed", "reopened"),
+			info:   salsa.SnapshotInfo{EventTypes: []string{"credited", "closed"}},
+			exp:    true,
+		},
+		{
+			name:   "after should not snapshot before the duration",
+			policy: salsa.SnapshotAfter(time.Hour),
+			info:   salsa.SnapshotInfo{Versions: salsa.Versions{Current: 1}, Elapsed: time.Minute},
+			exp:    false,
+		},
+		{
+			name:   "after should snapshot after the duration",
+			policy: salsa.SnapshotAfter(time.Hour),
+			info:   salsa.SnapshotInfo{Versions: salsa.Versions{Current: 1}, Elapsed: 2 * time.Hour},
+			exp:    true,
+		},
+		{
+			name:   "after should not snapshot without events",
+			policy: salsa.SnapshotAfter(time.Hour),
+			info:   salsa.SnapshotInfo{Versions: salsa.Versions{State: 1, Initial: 1, Current: 1}, Elapsed: 2 * time.Hour},
+			exp:    false,
+		},
+		{
+			name:   "never should not snapshot",
+			policy: salsa.SnapshotNever(),
+			info:   salsa.SnapshotInfo{Versions: salsa.Versions{Current: 1000}, EventTypes: []string{"event"}, Elapsed: time.Hour},
+			exp:    false,
+		},
+		{
+			name:   "any should not snapshot if no policies snapshot",
+			policy: salsa.SnapshotAny(salsa.SnapshotEvery(10), salsa.SnapshotOnEvents("closed")),
+			info:   salsa.SnapshotInfo{Versions: salsa.Versions{Current: 1}, EventTypes: []string{"credited"}},
+			exp:    false,
+		},
+		{
+			name:   "any should snapshot if a policy snapshots",
+			policy: salsa.SnapshotAny(salsa.SnapshotEvery(10), salsa.SnapshotOnEvents("closed")),
+			info:   salsa.SnapshotInfo{Versions: salsa.Versions{Current: 1}, EventTypes: []string{"closed"}},
+			exp:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			act := tt.policy.ShouldSnapshot(tt.info)
+			assertDeepEqual(t, act, tt.exp)
+		})
+	}
+}
+
+func TestWithSnapshotPolicy(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	tests := []struct {
+		name   string
+		policy salsa.SnapshotPolicy
+		exp    salsa.Versions
+	}{
+		{
+			name:   "should snapshot using the policy",
+			policy: salsa.SnapshotEvery(3),
+			exp:    salsa.Versions{State: 3, Initial: 4, Current: 4},
+		},
+		{
+			name:   "should not snapshot using the policy",
+			policy: salsa.SnapshotNever(),
+			exp:    salsa.Versions{State: 0, Initial: 4, Current: 4},
+		},
+		{
+			name:   "should pass the event types to the policy",
+			policy: salsa.SnapshotOnEvents("event"),
+			exp:    salsa.Versions{State: 4, Initial: 4, Current: 4},
+		},
+		{
+			name: "should pass the elapsed time to the policy",
+			policy: salsa.SnapshotPolicyFunc(func(i salsa.SnapshotInfo) bool {
+				return i.Elapsed >= 5*time.Millisecond
+			}),
+			exp: salsa.Versions{State: 4, Initial: 4, Current: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er), salsa.WithSnapshotPolicy[state](tt.policy))
+
+			a := new(salsa.Aggregate[state])
+			for i := 0; i < 4; i++ {
+				_, err := a.Apply(&event{Amount: 10})
+				assertErrorExists(t, err, false)
+
+				if i == 3 {
+					// allow time to elapse since the first unsnapshotted event
+					time.Sleep(5 * time.Millisecond)
+				}
+
+				err = sut.Save(context.Background(), "id", a)
+				assertErrorExists(t, err, false)
+			}
+
+			act, err := sut.Get(context.Background(), "id")
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act.Versions(), tt.exp)
+		})
+	}
+}
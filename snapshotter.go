@@ -0,0 +1,78 @@
+package salsa
+
+import (
+	"context"
+	"sync"
+)
+
+// snapshotter runs background snapshot writes with bounded concurrency
+type snapshotter struct {
+	sem chan struct{}
+	mu  sync.Mutex
+	err error
+}
+
+func newSnapshotter(concurrency int) *snapshotter {
+	return &snapshotter{
+		sem: make(chan struct{}, concurrency),
+	}
+}
+
+// run runs the specified func in the background, returning false if the maximum
+// number of concurrent writes are already running
+func (s *snapshotter) run(fn func() error) bool {
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		return false
+	}
+
+	go func() {
+		defer func() {
+			<-s.sem
+		}()
+
+		if err := fn(); err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if s.err == nil {
+				s.err = err
+			}
+		}
+	}()
+
+	return true
+}
+
+// flush waits for all running writes to complete, returning the first error since the previous flush
+func (s *snapshotter) flush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// acquiring every slot ensures that no writes are running
+	n := 0
+	defer func() {
+		for ; n > 0; n-- {
+			<-s.sem
+		}
+	}()
+
+	for n < cap(s.sem) {
+		select {
+		case s.sem <- struct{}{}:
+			n++
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.err
+	s.err = nil
+
+	return err
+}
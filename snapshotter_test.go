@@ -0,0 +1,104 @@
+package salsa_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stevecallear/salsa"
+)
+
+func TestStore_AsyncSnapshots(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	errState := errors.New("error")
+
+	tests := []struct {
+		name     string
+		db       salsa.DB[string]
+		versions salsa.Versions
+		exp      salsa.Versions
+		err      error
+	}{
+		{
+			name:     "should write the snapshot in the background",
+			db:       salsa.NewMemoryDB[string](),
+			versions: salsa.Versions{State: 3, Initial: 3, Current: 3},
+			exp:      salsa.Versions{State: 3, Initial: 3, Current: 3},
+		},
+		{
+			name:     "should return background errors on flush",
+			db:       &stateErrorDB{DB: salsa.NewMemoryDB[string](), err: errState},
+			versions: salsa.Versions{State: 3, Initial: 3, Current: 3},
+			exp:      salsa.Versions{State: 0, Initial: 3, Current: 3},
+			err:      errState,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := salsa.NewStore[string](tt.db,
+				salsa.WithResolver[state](er),
+				salsa.WithSnapshotRate[state](2),
+				salsa.WithAsyncSnapshots[state](2))
+
+			a := new(salsa.Aggregate[state])
+			for i := 0; i < 3; i++ {
+				_, err := a.Apply(&event{Amount: 10})
+				assertErrorExists(t, err, false)
+			}
+
+			err := sut.Save(context.Background(), "id", a)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, a.Versions(), tt.versions)
+
+			err = sut.Flush(context.Background())
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, expected %v", err, tt.err)
+			}
+
+			act, err := sut.Get(context.Background(), "id")
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act.Versions(), tt.exp)
+			assertDeepEqual(t, act.State(), state{Balance: 30})
+
+			err = sut.Flush(context.Background())
+			assertErrorExists(t, err, false)
+		})
+	}
+
+	t.Run("should return an error if the flush context is cancelled", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er), salsa.WithAsyncSnapshots[state](1))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := sut.Flush(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, expected %v", err, context.Canceled)
+		}
+	})
+}
+
+// stateErrorDB returns an error when writing states
+type stateErrorDB struct {
+	salsa.DB[string]
+	err error
+}
+
+type stateErrorTx struct {
+	salsa.DBTx
+	err error
+}
+
+func (d *stateErrorDB) Write(ctx context.Context, id string, fn func(salsa.DBTx) error) error {
+	return d.DB.Write(ctx, id, func(tx salsa.DBTx) error {
+		return fn(&stateErrorTx{DBTx: tx, err: d.err})
+	})
+}
+
+func (t *stateErrorTx) State(salsa.EncodedState) error {
+	return t.err
+}
@@ -3,40 +3,119 @@ package salsa
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
 type (
 	// Store represents an event store
 	Store[TI comparable, TS any] struct {
-		opts Options[TS]
-		db   DB[TI]
+		opts        Options[TS]
+		db          DB[TI]
+		snapshots   SnapshotStore[TI]
+		snapshotter *snapshotter
+		cache       *cache[TI, TS]
+		snapshotErr error
+		mu          sync.Mutex
 	}
 
 	// Options represents a set of store options
 	Options[TS any] struct {
-		SnapshotRate  int
-		Encoder       Encoder
-		Decoder       Decoder
-		EventResolver EventResolver[TS]
+		SnapshotRate   int
+		SnapshotPolicy SnapshotPolicy
+		AsyncSnapshots int
+		CacheSize      int
+		Outbox         bool
+		BeforeSave     []SaveHook[TS]
+		AfterSave      []SaveHook[TS]
+		Encoder        Encoder
+		Decoder        Decoder
+		EventResolver  EventResolver[TS]
+		Upcasters      map[UpcastKey]Upcaster
+		StateUpcasters map[uint]StateUpcaster
 	}
 
 	// EncodedState represents encoded state
 	EncodedState struct {
 		Version uint64
+		Schema  uint
 		Data    []byte
 	}
 
 	// Encoded event represents an encoded event
 	EncodedEvent struct {
-		Type    string
-		Version uint64
-		Data    []byte
+		Type     string
+		Version  uint64
+		Schema   uint
+		Position uint64
+		Data     []byte
+		Metadata Metadata
+	}
+
+	// StreamEvent represents an encoded event within the global stream
+	StreamEvent[TI comparable] struct {
+		ID TI
+		EncodedEvent
+	}
+
+	// RecordedEvent represents a decoded persisted event
+	RecordedEvent[TI comparable, TS any] struct {
+		ID       TI
+		Version  uint64
+		Position uint64
+		Metadata Metadata
+		Event    Event[TS]
 	}
 
 	// DB represents an events DB
 	DB[TI comparable] interface {
 		Read(ctx context.Context, id TI) (EncodedState, []EncodedEvent, error)
+		ReadEvents(ctx context.Context, id TI, from, to uint64) ([]EncodedEvent, error)
+		ReadAll(ctx context.Context, from uint64, limit int) ([]StreamEvent[TI], error)
 		Write(ctx context.Context, id TI, fn func(DBTx) error) error
+		PurgeStates(ctx context.Context, id TI) error
+		PurgeAllStates(ctx context.Context) error
+	}
+
+	// MultiDB represents an events DB that can write multiple aggregates in a single transaction.
+	// The write func is invoked for each aggregate id.
+	MultiDB[TI comparable] interface {
+		WriteMulti(ctx context.Context, ids []TI, fn func(TI, DBTx) error) error
+	}
+
+	// IterDB represents an events DB that can stream the events for an aggregate.
+	// An empty iterator and zero state version are returned if the aggregate does not exist.
+	IterDB[TI comparable] interface {
+		ReadIter(ctx context.Context, id TI) (EncodedState, EventIterator, error)
+	}
+
+	// VersionDB represents an events DB that can read an aggregate at a specific version.
+	// ReadAt returns the most recent state at or below the version, along with the subsequent
+	// events up to and including the version. ReadVersion returns the current version without
+	// reading the aggregate events.
+	VersionDB[TI comparable] interface {
+		ReadAt(ctx context.Context, id TI, version uint64) (EncodedState, []EncodedEvent, error)
+		ReadVersion(ctx context.Context, id TI) (uint64, error)
+	}
+
+	// EventIterator represents an iterator over encoded events in version order.
+	// Close must be called to release any underlying resources.
+	EventIterator interface {
+		Next() bool
+		Event() EncodedEvent
+		Err() error
+		Close() error
+	}
+
+	// SnapshotStore represents a state snapshot store that is separate from the events DB.
+	// ReadState returns the most recent state at or below the version, or the latest state if the
+	// version is zero. A zero state is returned if no snapshot exists.
+	SnapshotStore[TI comparable] interface {
+		ReadState(ctx context.Context, id TI, version uint64) (EncodedState, error)
+		WriteState(ctx context.Context, id TI, s EncodedState) error
+		PurgeStates(ctx context.Context, id TI) error
+		PurgeAllStates(ctx context.Context) error
 	}
 
 	// DBTx represents an events DB transaction
@@ -44,6 +123,47 @@ type (
 		Event(e EncodedEvent) error
 		State(s EncodedState) error
 	}
+
+	// OutboxTx represents an events DB transaction that can write events to a transactional outbox.
+	// Outbox must only be called for events that have been written in the same transaction.
+	OutboxTx interface {
+		Outbox(e EncodedEvent) error
+	}
+
+	// OutboxDB represents an events DB with a transactional outbox. ReadOutbox returns up to limit
+	// outbox events in position order, and DeleteOutbox deletes the outbox events with the specified positions.
+	OutboxDB[TI comparable] interface {
+		ReadOutbox(ctx context.Context, limit int) ([]StreamEvent[TI], error)
+		DeleteOutbox(ctx context.Context, positions ...uint64) error
+	}
+
+	// SaveOptions represents a set of save options
+	SaveOptions struct {
+		NewStream bool
+	}
+
+	// ExpectedVersion represents the expected aggregate version when appending events
+	ExpectedVersion int64
+)
+
+const (
+	// ExpectAny appends events regardless of the aggregate version
+	ExpectAny ExpectedVersion = -1
+
+	// ExpectNoStream appends events only if the aggregate does not exist
+	ExpectNoStream ExpectedVersion = 0
+
+	// ExpectStreamExists appends events only if the aggregate exists. A conflict error with an
+	// expected version of 1 and an actual version of 0 is returned if the aggregate does not exist.
+	ExpectStreamExists ExpectedVersion = -2
+)
+
+const (
+	// maxAppendAttempts is the maximum number of attempts to append events when the version is not known
+	maxAppendAttempts = 10
+
+	// asOfBatchSize is the number of events read at a time when retrieving an aggregate as of a time
+	asOfBatchSize = 100
 )
 
 // NewStore returns a new event store backed by the specified DB
@@ -61,82 +181,809 @@ func NewStore[TI comparable, TS any](db DB[TI], optFns ...func(*Options[TS])) *S
 		fn(&o)
 	}
 
-	return &Store[TI, TS]{
+	st := &Store[TI, TS]{
 		opts: o,
 		db:   db,
 	}
+
+	if o.AsyncSnapshots > 0 {
+		st.snapshotter = newSnapshotter(o.AsyncSnapshots)
+	}
+
+	if o.CacheSize > 0 {
+		st.cache = newCache[TI, TS](o.CacheSize)
+	}
+
+	return st
+}
+
+// NewStoreWithSnapshots returns a new event store backed by the specified DB, with state snapshots
+// persisted to the specified snapshot store. Snapshots are written once events have been committed,
+// so snapshot write errors do not fail the save and are instead returned by Flush.
+func NewStoreWithSnapshots[TI comparable, TS any](db DB[TI], ss SnapshotStore[TI], optFns ...func(*Options[TS])) *Store[TI, TS] {
+	s := NewStore[TI](db, optFns...)
+	s.snapshots = ss
+
+	return s
 }
 
-// Get retrieves the aggregate with the specified id
+// Get retrieves the aggregate with the specified id.
+// Events are streamed and applied individually if the DB implements IterDB.
+// If the aggregate state is cached then only subsequent events are read.
 func (s *Store[TI, TS]) Get(ctx context.Context, id TI) (*Aggregate[TS], error) {
-	es, ees, err := s.db.Read(ctx, id)
+	if s.cache != nil {
+		if e, ok := s.cache.get(id); ok {
+			return s.getCached(ctx, id, e)
+		}
+	}
+
+	es, it, err := s.readIter(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	var vs VersionedState[TS]
-	if es.Data != nil {
-		if err = s.opts.Decoder.Decode(es.Data, &vs.State); err != nil {
-			return nil, err
+	a, err := s.load(ctx, id, es, it, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.versions.Current < 1 {
+		return nil, ErrNotFound
+	}
+
+	if s.cache != nil {
+		s.cache.put(id, a)
+	}
+
+	return a, nil
+}
+
+// GetAt retrieves the aggregate with the specified id as it was at the specified version.
+// Events are replayed from the nearest snapshot at or below the version if the DB implements VersionDB.
+func (s *Store[TI, TS]) GetAt(ctx context.Context, id TI, version uint64) (*Aggregate[TS], error) {
+	if version < 1 {
+		return nil, ErrNotFound
+	}
+
+	es, ees, err := s.readAt(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := s.load(ctx, id, es, &sliceIterator{events: ees}, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.versions.Current != version {
+		return nil, ErrNotFound
+	}
+
+	return a, nil
+}
+
+// GetAsOf retrieves the aggregate with the specified id as it was at the specified time,
+// including all events with a timestamp at or before the time. Events are read in batches until
+// an event after the time is found, and the aggregate is then retrieved at that version.
+func (s *Store[TI, TS]) GetAsOf(ctx context.Context, id TI, t time.Time) (*Aggregate[TS], error) {
+	v, err := s.versionAt(ctx, id, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetAt(ctx, id, v)
+}
+
+// GetOrNew retrieves the aggregate with the specified id, or returns a new aggregate if it does not exist
+func (s *Store[TI, TS]) GetOrNew(ctx context.Context, id TI) (*Aggregate[TS], error) {
+	a, err := s.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return new(Aggregate[TS]), nil
+	}
+
+	return a, err
+}
+
+// Exists returns true if the aggregate with the specified id exists
+func (s *Store[TI, TS]) Exists(ctx context.Context, id TI) (bool, error) {
+	_, err := s.db.ReadEvents(ctx, id, 1, 1)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// ReadAll returns up to limit events from the global stream, starting at the specified position.
+// Events that the resolver does not recognise are skipped, so fewer than limit events may be returned.
+func (s *Store[TI, TS]) ReadAll(ctx context.Context, from uint64, limit int) ([]RecordedEvent[TI, TS], error) {
+	res, err := s.readAll(ctx, from, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	n := 0
+	for _, e := range res {
+		if e.Event != nil {
+			res[n] = e
+			n++
 		}
-		vs.Version = es.Version
 	}
 
-	des := make([]Event[TS], len(ees))
+	return res[:n], nil
+}
+
+// History returns the events for the specified aggregate within the version range, regardless of snapshots.
+// A zero to version returns all events from the specified version.
+func (s *Store[TI, TS]) History(ctx context.Context, id TI, from, to uint64) ([]RecordedEvent[TI, TS], error) {
+	ees, err := s.db.ReadEvents(ctx, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]RecordedEvent[TI, TS], len(ees))
 	for i, ee := range ees {
-		de, err := s.opts.EventResolver.Resolve(ee.Type)
-		if err != nil {
+		if res[i], err = s.recordedEvent(id, ee); err != nil {
 			return nil, err
 		}
+	}
 
-		if err = s.opts.Decoder.Decode(ee.Data, de); err != nil {
-			return nil, err
+	return res, nil
+}
+
+// Save saves the specified aggregate. If the new stream option is specified then a conflict
+// error is returned if the aggregate already exists.
+func (s *Store[TI, TS]) Save(ctx context.Context, id TI, a *Aggregate[TS], optFns ...func(*SaveOptions)) error {
+	var o SaveOptions
+	for _, fn := range optFns {
+		fn(&o)
+	}
+
+	v := a.Versions()
+	if o.NewStream {
+		if v.Initial > 0 {
+			return &ConflictError{ID: id, Expected: 0, Actual: v.Initial}
 		}
 
-		des[i] = de
+		if v.Current == 0 {
+			// there are no events to write, so the stream must be checked explicitly
+			av, err := s.version(ctx, id)
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			return &ConflictError{ID: id, Expected: 0, Actual: av}
+		}
 	}
 
-	return NewAggregate(vs, des...)
+	es := a.Events()
+	ees, err := s.encodeEvents(ctx, a)
+	if err != nil {
+		return err
+	}
+
+	if err = runSaveHooks(ctx, s.opts.BeforeSave, id, ees, es); err != nil {
+		return err
+	}
+
+	var snapshot bool
+	err = s.db.Write(ctx, id, func(tx DBTx) error {
+		var err error
+		snapshot, err = s.write(tx, a, ees)
+		return err
+	})
+	if err != nil {
+		s.invalidate(id, err)
+		return err
+	}
+
+	s.commit(ctx, id, a, snapshot)
+	return runSaveHooks(ctx, s.opts.AfterSave, id, ees, es)
 }
 
-// Save saves the specified aggregate
-func (s *Store[TI, TS]) Save(ctx context.Context, id TI, a *Aggregate[TS]) error {
-	var err error
-	var b []byte
-	return s.db.Write(ctx, id, func(tx DBTx) error {
-		v := a.Versions()
+// SaveAll saves the specified aggregates in a single transaction. ErrUnsupported
+// is returned if the DB does not support multi-aggregate writes.
+func (s *Store[TI, TS]) SaveAll(ctx context.Context, as map[TI]*Aggregate[TS]) error {
+	mdb, ok := s.db.(MultiDB[TI])
+	if !ok {
+		return ErrUnsupported
+	}
 
-		for i, e := range a.Events() {
-			b, err = s.opts.Encoder.Encode(e)
-			if err != nil {
-				return err
+	ids := make([]TI, 0, len(as))
+	es := make(map[TI][]Event[TS], len(as))
+	ees := make(map[TI][]EncodedEvent, len(as))
+	for id, a := range as {
+		var err error
+		if ees[id], err = s.encodeEvents(ctx, a); err != nil {
+			return err
+		}
+
+		if err = runSaveHooks(ctx, s.opts.BeforeSave, id, ees[id], a.Events()); err != nil {
+			return err
+		}
+
+		ids = append(ids, id)
+		es[id] = a.Events()
+	}
+
+	snapshots := make(map[TI]bool, len(as))
+	err := mdb.WriteMulti(ctx, ids, func(id TI, tx DBTx) error {
+		var err error
+		snapshots[id], err = s.write(tx, as[id], ees[id])
+		return err
+	})
+	if err != nil {
+		for _, id := range ids {
+			s.invalidate(id, err)
+		}
+		return err
+	}
+
+	for id, a := range as {
+		s.commit(ctx, id, a, snapshots[id])
+	}
+
+	// the events are committed, so hooks are invoked for every aggregate regardless of errors
+	var herr error
+	for _, id := range ids {
+		if err = runSaveHooks(ctx, s.opts.AfterSave, id, ees[id], es[id]); err != nil && herr == nil {
+			herr = err
+		}
+	}
+
+	return herr
+}
+
+// Append appends the events to the specified aggregate without loading it, returning the
+// new aggregate version. The expected version is either the current aggregate version or
+// one of ExpectAny, ExpectNoStream or ExpectStreamExists. Snapshots are not written.
+func (s *Store[TI, TS]) Append(ctx context.Context, id TI, ev ExpectedVersion, es ...Event[TS]) (uint64, error) {
+	switch {
+	case ev >= 0:
+		return s.append(ctx, id, uint64(ev), es)
+	case ev != ExpectAny && ev != ExpectStreamExists:
+		return 0, fmt.Errorf("invalid expected version: %d", ev)
+	}
+
+	var err error
+	for i := 0; i < maxAppendAttempts; i++ {
+		var v uint64
+		v, err = s.version(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			if ev == ExpectStreamExists {
+				return 0, &ConflictError{ID: id, Expected: 1, Actual: 0}
 			}
+			v, err = 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var nv uint64
+		nv, err = s.append(ctx, id, v, es)
+		if !errors.Is(err, ErrConflict) {
+			return nv, err
+		}
+	}
+
+	return 0, err
+}
+
+// Flush waits for any background snapshot writes to complete, returning the first
+// background or snapshot store write error since the previous flush
+func (s *Store[TI, TS]) Flush(ctx context.Context) error {
+	if s.snapshotter != nil {
+		if err := s.snapshotter.flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.snapshotErr
+	s.snapshotErr = nil
+
+	return err
+}
+
+// PurgeSnapshots deletes all snapshots for the specified aggregate
+func (s *Store[TI, TS]) PurgeSnapshots(ctx context.Context, id TI) error {
+	if s.cache != nil {
+		s.cache.remove(id)
+	}
+
+	if s.snapshots != nil {
+		return s.snapshots.PurgeStates(ctx, id)
+	}
+
+	return s.db.PurgeStates(ctx, id)
+}
+
+// PurgeAllSnapshots deletes all snapshots for all aggregates
+func (s *Store[TI, TS]) PurgeAllSnapshots(ctx context.Context) error {
+	if s.cache != nil {
+		s.cache.clear()
+	}
+
+	if s.snapshots != nil {
+		return s.snapshots.PurgeAllStates(ctx)
+	}
+
+	return s.db.PurgeAllStates(ctx)
+}
+
+// encodeEvents encodes the events applied to the aggregate since it was loaded
+func (s *Store[TI, TS]) encodeEvents(ctx context.Context, a *Aggregate[TS]) ([]EncodedEvent, error) {
+	// events are written from version 1 for new aggregates, so the backend
+	// version check ensures that the stream does not exist
+	v := a.Versions()
+	es := a.Events()
+	ms := a.metadata[len(a.metadata)-len(es):]
+
+	ees := make([]EncodedEvent, len(es))
+	for i, e := range es {
+		ms[i] = ms[i].withContext(ctx)
+
+		var err error
+		if ees[i], err = s.encodeEvent(e, v.Initial+uint64(i+1), ms[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return ees, nil
+}
+
+// write writes the encoded aggregate events and, if required, a state snapshot.
+// It returns true if a snapshot was written.
+func (s *Store[TI, TS]) write(tx DBTx, a *Aggregate[TS], ees []EncodedEvent) (bool, error) {
+	for _, ee := range ees {
+		if err := s.writeEvent(tx, ee); err != nil {
+			return false, err
+		}
+	}
+
+	v := a.Versions()
+
+	p := s.opts.SnapshotPolicy
+	if p == nil {
+		p = snapshotRate(s.opts.SnapshotRate)
+	}
+
+	if !p.ShouldSnapshot(newSnapshotInfo(a, time.Now())) {
+		return false, nil
+	}
+
+	if s.snapshotter != nil || s.snapshots != nil {
+		// the snapshot is written separately once the events are committed
+		return true, nil
+	}
+
+	st, err := s.encodeState(v.Current, a.State())
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.State(st)
+	return err == nil, err
+}
+
+// writeEvent writes the encoded event, along with the outbox event if configured.
+// ErrUnsupported is returned if the outbox is configured and the DB does not support it.
+func (s *Store[TI, TS]) writeEvent(tx DBTx, ee EncodedEvent) error {
+	if err := tx.Event(ee); err != nil {
+		return err
+	}
 
-			if err = tx.Event(EncodedEvent{
-				Type:    e.Type(),
-				Version: v.Initial + uint64(i+1),
-				Data:    b,
-			}); err != nil {
+	if !s.opts.Outbox {
+		return nil
+	}
+
+	otx, ok := tx.(OutboxTx)
+	if !ok {
+		return ErrUnsupported
+	}
+
+	return otx.Outbox(ee)
+}
+
+// commit marks the saved aggregate events as committed, writing the snapshot if it was not written
+// with the events. Background snapshots are committed once queued, and are skipped if the snapshotter is busy.
+// The events have already been written, so snapshot store errors are retained for Flush rather than returned.
+func (s *Store[TI, TS]) commit(ctx context.Context, id TI, a *Aggregate[TS], snapshot bool) {
+	a.Commit()
+	if s.cache != nil && a.versions.Current > 0 {
+		defer s.cache.put(id, a)
+	}
+
+	if !snapshot {
+		return
+	}
+
+	v, state := a.versions.Current, a.state
+	switch {
+	case s.snapshotter != nil:
+		ok := s.snapshotter.run(func() error {
+			return s.writeSnapshot(context.Background(), id, v, state)
+		})
+		if !ok {
+			return
+		}
+	case s.snapshots != nil:
+		if err := s.writeSnapshot(ctx, id, v, state); err != nil {
+			s.snapshotFailed(err)
+			return
+		}
+	}
+
+	a.commitState()
+}
+
+// snapshotFailed retains the first snapshot store write error since the previous flush
+func (s *Store[TI, TS]) snapshotFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.snapshotErr == nil {
+		s.snapshotErr = err
+	}
+}
+
+// writeSnapshot writes the state snapshot separately from the events
+func (s *Store[TI, TS]) writeSnapshot(ctx context.Context, id TI, version uint64, state TS) error {
+	es, err := s.encodeState(version, state)
+	if err != nil {
+		return err
+	}
+
+	if s.snapshots != nil {
+		return s.snapshots.WriteState(ctx, id, es)
+	}
+
+	err = s.db.Write(ctx, id, func(tx DBTx) error {
+		return tx.State(es)
+	})
+
+	// a conflict indicates that newer events have been written, so the snapshot is stale
+	if errors.Is(err, ErrConflict) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *Store[TI, TS]) encodeState(version uint64, state TS) (EncodedState, error) {
+	b, err := s.opts.Encoder.Encode(state)
+	if err != nil {
+		return EncodedState{}, err
+	}
+
+	return EncodedState{
+		Version: version,
+		Schema:  schemaVersion(state),
+		Data:    b,
+	}, nil
+}
+
+func (s *Store[TI, TS]) append(ctx context.Context, id TI, v uint64, es []Event[TS]) (uint64, error) {
+	ees := make([]EncodedEvent, len(es))
+	for i, e := range es {
+		var err error
+		if ees[i], err = s.encodeEvent(e, v+uint64(i+1), newMetadata().withContext(ctx)); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := runSaveHooks(ctx, s.opts.BeforeSave, id, ees, es); err != nil {
+		return 0, err
+	}
+
+	err := s.db.Write(ctx, id, func(tx DBTx) error {
+		for _, ee := range ees {
+			if err := s.writeEvent(tx, ee); err != nil {
 				return err
 			}
 		}
 
-		if v.Current-v.State > uint64(s.opts.SnapshotRate) {
-			b, err = s.opts.Encoder.Encode(a.State())
+		return nil
+	})
+	if err != nil {
+		s.invalidate(id, err)
+		return 0, err
+	}
+
+	return v + uint64(len(es)), runSaveHooks(ctx, s.opts.AfterSave, id, ees, es)
+}
+
+// getCached returns the aggregate with the cached state, applying any events written after the cached version
+func (s *Store[TI, TS]) getCached(ctx context.Context, id TI, e cacheEntry[TI, TS]) (*Aggregate[TS], error) {
+	ees, err := s.db.ReadEvents(ctx, id, e.state.Version+1, 0)
+	if err != nil {
+		s.cache.remove(id)
+		return nil, err
+	}
+
+	a := e.aggregate()
+	for _, ee := range ees {
+		de, err := s.decodeEvent(ee)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = a.load(de, ee.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ees) > 0 {
+		s.cache.put(id, a)
+	}
+
+	return a, nil
+}
+
+// invalidate removes the cached state for the specified aggregate if the error is a conflict,
+// as the cached state may be stale
+func (s *Store[TI, TS]) invalidate(id TI, err error) {
+	if s.cache != nil && errors.Is(err, ErrConflict) {
+		s.cache.remove(id)
+	}
+}
+
+// load returns a new aggregate with the specified state and events, closing the iterator.
+// A non-zero to version limits the events read if the state snapshot is stale.
+func (s *Store[TI, TS]) load(ctx context.Context, id TI, es EncodedState, it EventIterator, to uint64) (*Aggregate[TS], error) {
+	defer func() {
+		it.Close()
+	}()
+
+	var vs VersionedState[TS]
+	if es.Data != nil {
+		var ok bool
+		var err error
+		if vs, ok, err = s.decodeState(es); err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			// the snapshot schema is stale, so rebuild from all events
+			if err = it.Close(); err != nil {
+				return nil, err
+			}
+
+			ees, err := s.db.ReadEvents(ctx, id, 1, to)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
-			if err = tx.State(EncodedState{
-				Version: v.Current,
-				Data:    b,
-			}); err != nil {
-				return err
+			it = &sliceIterator{events: ees}
+		}
+	}
+
+	a, err := NewAggregate(vs)
+	if err != nil {
+		return nil, err
+	}
+
+	for it.Next() {
+		ee := it.Event()
+
+		e, err := s.decodeEvent(ee)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = a.load(e, ee.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = it.Err(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// readAt returns the nearest state at or below the version and the subsequent events up to the version,
+// reading all events up to the version if the DB does not implement VersionDB
+func (s *Store[TI, TS]) readAt(ctx context.Context, id TI, version uint64) (EncodedState, []EncodedEvent, error) {
+	if s.snapshots != nil {
+		return s.readSnapshot(ctx, id, version)
+	}
+
+	if vdb, ok := s.db.(VersionDB[TI]); ok {
+		return vdb.ReadAt(ctx, id, version)
+	}
+
+	ees, err := s.db.ReadEvents(ctx, id, 1, version)
+	return EncodedState{}, ees, err
+}
+
+// versionAt reads the events for the specified id in batches, returning the version of the
+// last event with a timestamp at or before the specified time
+func (s *Store[TI, TS]) versionAt(ctx context.Context, id TI, t time.Time) (uint64, error) {
+	var v uint64
+	for from := uint64(1); ; from += asOfBatchSize {
+		ees, err := s.db.ReadEvents(ctx, id, from, from+asOfBatchSize-1)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, ee := range ees {
+			if ee.Metadata.Timestamp.After(t) {
+				return v, nil
 			}
+			v = ee.Version
 		}
 
-		return nil
-	})
+		if len(ees) < asOfBatchSize {
+			return v, nil
+		}
+	}
+}
+
+// readIter returns the state and an event iterator for the specified id, reading all events
+// into memory if a snapshot store is configured or the DB does not implement IterDB
+func (s *Store[TI, TS]) readIter(ctx context.Context, id TI) (EncodedState, EventIterator, error) {
+	var es EncodedState
+	var ees []EncodedEvent
+	var err error
+
+	switch idb, ok := s.db.(IterDB[TI]); {
+	case s.snapshots != nil:
+		es, ees, err = s.readSnapshot(ctx, id, 0)
+	case ok:
+		return idb.ReadIter(ctx, id)
+	default:
+		es, ees, err = s.db.Read(ctx, id)
+	}
+	if err != nil {
+		return EncodedState{}, nil, err
+	}
+
+	return es, &sliceIterator{events: ees}, nil
+}
+
+// readSnapshot reads the most recent state at or below the version from the snapshot store, along with
+// the subsequent events up to the version. A zero version reads the latest state and all subsequent events.
+func (s *Store[TI, TS]) readSnapshot(ctx context.Context, id TI, version uint64) (EncodedState, []EncodedEvent, error) {
+	es, err := s.snapshots.ReadState(ctx, id, version)
+	if err != nil {
+		return EncodedState{}, nil, err
+	}
+
+	if es.Version > 0 && es.Version == version {
+		return es, nil, nil
+	}
+
+	ees, err := s.db.ReadEvents(ctx, id, es.Version+1, version)
+	if err != nil {
+		return EncodedState{}, nil, err
+	}
+
+	return es, ees, nil
+}
+
+// version returns the current version of the specified aggregate, reading all events since
+// the latest state if the DB does not implement VersionDB
+func (s *Store[TI, TS]) version(ctx context.Context, id TI) (uint64, error) {
+	if vdb, ok := s.db.(VersionDB[TI]); ok {
+		return vdb.ReadVersion(ctx, id)
+	}
+
+	es, ees, err := s.db.Read(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ees) > 0 {
+		return ees[len(ees)-1].Version, nil
+	}
+
+	return es.Version, nil
+}
+
+func (s *Store[TI, TS]) encodeEvent(e Event[TS], version uint64, m Metadata) (EncodedEvent, error) {
+	b, err := s.opts.Encoder.Encode(e)
+	if err != nil {
+		return EncodedEvent{}, err
+	}
+
+	return EncodedEvent{
+		Type:     e.Type(),
+		Version:  version,
+		Schema:   schemaVersion(e),
+		Data:     b,
+		Metadata: m,
+	}, nil
+}
+
+// decodeState decodes the encoded state, returning false if the upcast
+// schema version does not match the current state schema version
+func (s *Store[TI, TS]) decodeState(es EncodedState) (VersionedState[TS], bool, error) {
+	es, err := upcastState(s.opts.StateUpcasters, es)
+	if err != nil {
+		return VersionedState[TS]{}, false, err
+	}
+
+	var vs VersionedState[TS]
+	if es.Schema != schemaVersion(vs.State) {
+		return VersionedState[TS]{}, false, nil
+	}
+
+	vs.Version = es.Version
+	if err = s.opts.Decoder.Decode(es.Data, &vs.State); err != nil {
+		return VersionedState[TS]{}, false, err
+	}
+
+	return vs, true, nil
+}
+
+// readAll reads up to limit events from the global stream, starting at the specified position.
+// Events of unknown types are returned without a decoded event.
+func (s *Store[TI, TS]) readAll(ctx context.Context, from uint64, limit int) ([]RecordedEvent[TI, TS], error) {
+	ses, err := s.db.ReadAll(ctx, from, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]RecordedEvent[TI, TS], len(ses))
+	for i, se := range ses {
+		res[i], err = s.recordedEvent(se.ID, se.EncodedEvent)
+		if errors.Is(err, ErrUnknownEvent) {
+			res[i] = RecordedEvent[TI, TS]{ID: se.ID, Version: se.Version, Position: se.Position, Metadata: se.Metadata}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (s *Store[TI, TS]) recordedEvent(id TI, ee EncodedEvent) (RecordedEvent[TI, TS], error) {
+	de, err := s.decodeEvent(ee)
+	if err != nil {
+		return RecordedEvent[TI, TS]{}, err
+	}
+
+	return RecordedEvent[TI, TS]{
+		ID:       id,
+		Version:  ee.Version,
+		Position: ee.Position,
+		Metadata: ee.Metadata,
+		Event:    de,
+	}, nil
+}
+
+func (s *Store[TI, TS]) decodeEvent(ee EncodedEvent) (Event[TS], error) {
+	ee, err := upcastEvent(s.opts.Upcasters, ee)
+	if err != nil {
+		return nil, err
+	}
+
+	de, err := s.opts.EventResolver.Resolve(ee.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.opts.Decoder.Decode(ee.Data, de); err != nil {
+		return nil, err
+	}
+
+	return de, nil
+}
+
+// WithNewStream configures the save to require that the aggregate does not exist
+func WithNewStream() func(*SaveOptions) {
+	return func(o *SaveOptions) {
+		o.NewStream = true
+	}
 }
 
 // WithSnapshotRate configures the store to snapshot at the specified rate
@@ -145,3 +992,11 @@ func WithSnapshotRate[T any](rate int) func(*Options[T]) {
 		o.SnapshotRate = rate
 	}
 }
+
+// WithOutbox configures the store to write saved and appended events to the transactional outbox
+// in the same transaction. The DB must implement OutboxDB.
+func WithOutbox[T any]() func(*Options[T]) {
+	return func(o *Options[T]) {
+		o.Outbox = true
+	}
+}
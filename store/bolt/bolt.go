@@ -3,7 +3,9 @@ package bolt
 import (
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"time"
 
 	"go.etcd.io/bbolt"
 
@@ -16,7 +18,41 @@ type (
 	}
 
 	tx struct {
+		id        string
+		btx       *bbolt.Tx
+		bucket    *bbolt.Bucket
+		all       *bbolt.Bucket
+		positions map[uint64]uint64
+	}
+
+	pointer struct {
+		ID      string `json:"id"`
+		Version uint64 `json:"version"`
+		Type    string `json:"type"`
+	}
+
+	header struct {
+		Schema        uint              `json:"schema,omitempty"`
+		Position      uint64            `json:"position"`
+		EventID       string            `json:"eventId,omitempty"`
+		Timestamp     time.Time         `json:"timestamp"`
+		CorrelationID string            `json:"correlationId,omitempty"`
+		CausationID   string            `json:"causationId,omitempty"`
+		Headers       map[string]string `json:"headers,omitempty"`
+	}
+
+	iterator struct {
+		ctx    context.Context
+		btx    *bbolt.Tx
 		bucket *bbolt.Bucket
+		cursor *bbolt.Cursor
+		from   []byte
+		event  salsa.EncodedEvent
+		err    error
+	}
+
+	stateHeader struct {
+		Schema uint `json:"schema,omitempty"`
 	}
 
 	itemType uint8
@@ -25,42 +61,110 @@ type (
 const (
 	itemTypeEvent itemType = iota + 1
 	itemTypeState
+	itemTypeHeader
+	itemTypeStateHeader
 )
 
+// allBucket is the name of the bucket containing the global event stream.
+// It must not be used as an aggregate id.
+var allBucket = []byte("$all")
+
+// outboxBucket is the name of the bucket containing the transactional outbox.
+// It must not be used as an aggregate id.
+var outboxBucket = []byte("$outbox")
+
 // New returns a new event store backed by boltdb
 func New[T any](bdb *bbolt.DB, optFns ...func(*salsa.Options[T])) *salsa.Store[string, T] {
-	return salsa.NewStore[string](&db{bdb: bdb}, optFns...)
+	return salsa.NewStore[string](NewDB(bdb), optFns...)
+}
+
+// NewDB returns a new events DB backed by boltdb
+func NewDB(bdb *bbolt.DB) salsa.DB[string] {
+	return &db{bdb: bdb}
 }
 
 // Read reads most recent state and events for the specified id
 func (d *db) Read(ctx context.Context, id string) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	return d.read(ctx, id, 0)
+}
+
+// ReadAt reads the most recent state at or below the version and the subsequent events up to the version
+func (d *db) ReadAt(ctx context.Context, id string, version uint64) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	return d.read(ctx, id, version)
+}
+
+// ReadVersion reads the current version for the specified id
+func (d *db) ReadVersion(ctx context.Context, id string) (uint64, error) {
+	var v uint64
+	err := d.bdb.View(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if v = newTx(btx, id).version(); v == 0 {
+			return salsa.ErrNotFound
+		}
+		return nil
+	})
+
+	return v, err
+}
+
+// read reads the most recent state and events up to the specified version.
+// A zero version reads the latest state and events.
+func (d *db) read(ctx context.Context, id string, version uint64) (salsa.EncodedState, []salsa.EncodedEvent, error) {
 	var state salsa.EncodedState
 	var events []salsa.EncodedEvent
 
 	err := d.bdb.View(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		bu := btx.Bucket([]byte(id))
 		if bu == nil {
-			return errors.New("not found")
+			return salsa.ErrNotFound
 		}
 
 		c := bu.Cursor()
+		hdrs := map[uint64]header{}
+		shdrs := map[uint64]stateHeader{}
+
+		// keys are ordered by big-endian version, so seek past the version and read backwards
+		k, v := c.Last()
+		if version > 0 {
+			if k, _ = c.Seek(encodeKey(version+1, 0, "")); k != nil {
+				k, v = c.Prev()
+			} else {
+				k, v = c.Last()
+			}
+		}
 
 	loop:
-		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		for ; k != nil; k, v = c.Prev() {
 			ver, ityp, etyp := decodeKey(k)
 			switch ityp {
 			case itemTypeState:
 				state = salsa.EncodedState{
 					Version: ver,
-					Data:    v,
+					Schema:  shdrs[ver].Schema,
+					Data:    copyBytes(v),
 				}
 				break loop
+			case itemTypeStateHeader:
+				var h stateHeader
+				if err := json.Unmarshal(v, &h); err != nil {
+					return err
+				}
+				shdrs[ver] = h
+			case itemTypeHeader:
+				var h header
+				if err := json.Unmarshal(v, &h); err != nil {
+					return err
+				}
+				hdrs[ver] = h
 			case itemTypeEvent:
-				events = append(events, salsa.EncodedEvent{
-					Type:    etyp,
-					Version: ver,
-					Data:    v,
-				})
+				events = append(events, hdrs[ver].event(etyp, ver, copyBytes(v)))
 			default:
 				return errors.New("invalid item type")
 			}
@@ -75,37 +179,539 @@ func (d *db) Read(ctx context.Context, id string) (salsa.EncodedState, []salsa.E
 	return state, events, nil
 }
 
+// ReadIter reads the most recent state and returns an iterator over subsequent events for the specified id.
+// The iterator holds a read transaction open until it is closed.
+func (d *db) ReadIter(ctx context.Context, id string) (salsa.EncodedState, salsa.EventIterator, error) {
+	if err := ctx.Err(); err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	btx, err := d.bdb.Begin(false)
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	bu := btx.Bucket([]byte(id))
+	if bu == nil {
+		if err = btx.Rollback(); err != nil {
+			return salsa.EncodedState{}, nil, err
+		}
+		return salsa.EncodedState{}, new(iterator), nil
+	}
+
+	var state salsa.EncodedState
+	c := bu.Cursor()
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		ver, ityp, _ := decodeKey(k)
+		if ityp != itemTypeState {
+			continue
+		}
+
+		var h stateHeader
+		if err = unmarshalHeader(bu.Get(encodeKey(ver, itemTypeStateHeader, "")), &h); err != nil {
+			btx.Rollback()
+			return salsa.EncodedState{}, nil, err
+		}
+
+		state = salsa.EncodedState{
+			Version: ver,
+			Schema:  h.Schema,
+			Data:    copyBytes(v),
+		}
+		break
+	}
+
+	return state, &iterator{
+		ctx:    ctx,
+		btx:    btx,
+		bucket: bu,
+		cursor: c,
+		from:   encodeKey(state.Version+1, 0, ""),
+	}, nil
+}
+
+// ReadEvents reads the events for the specified id within the version range.
+// A zero to version reads all events from the specified version.
+func (d *db) ReadEvents(ctx context.Context, id string, from, to uint64) ([]salsa.EncodedEvent, error) {
+	var events []salsa.EncodedEvent
+
+	err := d.bdb.View(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		bu := btx.Bucket([]byte(id))
+		if bu == nil {
+			return salsa.ErrNotFound
+		}
+
+		type item struct {
+			etyp string
+			ver  uint64
+			data []byte
+		}
+
+		var itms []item
+		hdrs := map[uint64]header{}
+
+		c := bu.Cursor()
+		for k, v := c.Seek(encodeKey(from, 0, "")); k != nil; k, v = c.Next() {
+			ver, ityp, etyp := decodeKey(k)
+			if to > 0 && ver > to {
+				break
+			}
+
+			switch ityp {
+			case itemTypeEvent:
+				itms = append(itms, item{etyp: etyp, ver: ver, data: copyBytes(v)})
+			case itemTypeHeader:
+				var h header
+				if err := json.Unmarshal(v, &h); err != nil {
+					return err
+				}
+				hdrs[ver] = h
+			}
+		}
+
+		events = make([]salsa.EncodedEvent, len(itms))
+		for i, itm := range itms {
+			events[i] = hdrs[itm.ver].event(itm.etyp, itm.ver, itm.data)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
 // Write executes the specified write function within a transaction
 func (d *db) Write(ctx context.Context, id string, fn func(salsa.DBTx) error) error {
 	return d.bdb.Update(func(btx *bbolt.Tx) error {
-		bu, err := btx.CreateBucketIfNotExists([]byte(id))
-		if err != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		return fn(newTx(btx, id))
+	})
+}
+
+// WriteMulti executes the specified write function for each id within a single transaction
+func (d *db) WriteMulti(ctx context.Context, ids []string, fn func(string, salsa.DBTx) error) error {
+	return d.bdb.Update(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := fn(id, newTx(btx, id)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ReadAll returns up to limit events from the global stream, starting at the specified position
+func (d *db) ReadAll(ctx context.Context, from uint64, limit int) ([]salsa.StreamEvent[string], error) {
+	var events []salsa.StreamEvent[string]
+
+	err := d.bdb.View(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		all := btx.Bucket(allBucket)
+		if all == nil {
+			return nil
+		}
+
+		c := all.Cursor()
+		for k, v := c.Seek(encodePosition(from)); k != nil; k, v = c.Next() {
+			if limit > 0 && len(events) >= limit {
+				break
+			}
+
+			e, err := readPointer(btx, v)
+			if err != nil {
+				return err
+			}
+
+			events = append(events, e)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// ReadOutbox returns up to limit outbox events in position order
+func (d *db) ReadOutbox(ctx context.Context, limit int) ([]salsa.StreamEvent[string], error) {
+	var events []salsa.StreamEvent[string]
+
+	err := d.bdb.View(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ob := btx.Bucket(outboxBucket)
+		if ob == nil {
+			return nil
+		}
+
+		c := ob.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if limit > 0 && len(events) >= limit {
+				break
+			}
+
+			e, err := readPointer(btx, v)
+			if err != nil {
+				return err
+			}
+
+			events = append(events, e)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// DeleteOutbox deletes the outbox events with the specified positions
+func (d *db) DeleteOutbox(ctx context.Context, positions ...uint64) error {
+	return d.bdb.Update(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ob := btx.Bucket(outboxBucket)
+		if ob == nil {
+			return nil
+		}
+
+		for _, p := range positions {
+			if err := ob.Delete(encodePosition(p)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// PurgeStates deletes all states for the specified id
+func (d *db) PurgeStates(ctx context.Context, id string) error {
+	return d.bdb.Update(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		bu := btx.Bucket([]byte(id))
+		if bu == nil {
+			return nil
+		}
+
+		return purgeStates(bu)
+	})
+}
+
+// PurgeAllStates deletes all states for all ids
+func (d *db) PurgeAllStates(ctx context.Context) error {
+	return d.bdb.Update(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
-		tx := &tx{bucket: bu}
-		return fn(tx)
+		return btx.ForEach(func(name []byte, bu *bbolt.Bucket) error {
+			if isReserved(name) {
+				return nil
+			}
+
+			return purgeStates(bu)
+		})
 	})
 }
 
+func newTx(btx *bbolt.Tx, id string) *tx {
+	// buckets are created on first write to avoid leaving empty aggregate buckets
+	return &tx{id: id, btx: btx, bucket: btx.Bucket([]byte(id)), all: btx.Bucket(allBucket)}
+}
+
+// createBuckets creates the aggregate and global stream buckets if they do not exist
+func (t *tx) createBuckets() error {
+	var err error
+	if t.bucket == nil {
+		if t.bucket, err = t.btx.CreateBucketIfNotExists([]byte(t.id)); err != nil {
+			return err
+		}
+	}
+
+	if t.all == nil {
+		if t.all, err = t.btx.CreateBucketIfNotExists(allBucket); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Event writes the specified event
 func (t *tx) Event(e salsa.EncodedEvent) error {
-	k := encodeKey(e.Version, itemTypeEvent, e.Type)
-	if b := t.bucket.Get(k); b != nil {
-		return errors.New("version conflict")
+	if v := t.version(); e.Version != v+1 {
+		return &salsa.ConflictError{ID: t.id, Expected: e.Version - 1, Actual: v}
+	}
+
+	if err := t.createBuckets(); err != nil {
+		return err
+	}
+
+	if err := t.bucket.Put(encodeKey(e.Version, itemTypeEvent, e.Type), e.Data); err != nil {
+		return err
+	}
+
+	pos, err := t.all.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	h := newHeader(e.Metadata)
+	h.Schema = e.Schema
+	h.Position = pos
+
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	if err = t.bucket.Put(encodeKey(e.Version, itemTypeHeader, ""), b); err != nil {
+		return err
+	}
+
+	b, err = json.Marshal(pointer{ID: t.id, Version: e.Version, Type: e.Type})
+	if err != nil {
+		return err
+	}
+
+	if t.positions == nil {
+		t.positions = map[uint64]uint64{}
+	}
+	t.positions[e.Version] = pos
+
+	return t.all.Put(encodePosition(pos), b)
+}
+
+// Outbox writes the specified event to the outbox. The outbox stores a pointer to the
+// event, keyed by the global position.
+func (t *tx) Outbox(e salsa.EncodedEvent) error {
+	pos, ok := t.positions[e.Version]
+	if !ok {
+		return errors.New("outbox event not written in transaction")
+	}
+
+	ob, err := t.btx.CreateBucketIfNotExists(outboxBucket)
+	if err != nil {
+		return err
 	}
 
-	return t.bucket.Put(k, e.Data)
+	k := encodePosition(pos)
+	return ob.Put(k, t.all.Get(k))
 }
 
 // State writes the specified state
 func (t *tx) State(s salsa.EncodedState) error {
-	k := encodeKey(s.Version, itemTypeState, "")
-	if b := t.bucket.Get(k); b != nil {
-		return errors.New("version conflict")
+	if v := t.version(); s.Version != v {
+		return &salsa.ConflictError{ID: t.id, Expected: s.Version, Actual: v}
+	}
+
+	if err := t.createBuckets(); err != nil {
+		return err
+	}
+
+	if err := t.bucket.Put(encodeKey(s.Version, itemTypeState, ""), s.Data); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(stateHeader{Schema: s.Schema})
+	if err != nil {
+		return err
 	}
 
-	return t.bucket.Put(k, s.Data)
+	return t.bucket.Put(encodeKey(s.Version, itemTypeStateHeader, ""), b)
+}
+
+func (t *tx) version() uint64 {
+	if t.bucket == nil {
+		return 0
+	}
+
+	k, _ := t.bucket.Cursor().Last()
+	if k == nil {
+		return 0
+	}
+
+	v, _, _ := decodeKey(k)
+	return v
+}
+
+// Next advances the iterator to the next event
+func (i *iterator) Next() bool {
+	if i.btx == nil || i.err != nil {
+		return false
+	}
+
+	if i.err = i.ctx.Err(); i.err != nil {
+		return false
+	}
+
+	var k, v []byte
+	if i.from != nil {
+		k, v = i.cursor.Seek(i.from)
+		i.from = nil
+	} else {
+		k, v = i.cursor.Next()
+	}
+
+	for ; k != nil; k, v = i.cursor.Next() {
+		ver, ityp, etyp := decodeKey(k)
+		if ityp != itemTypeEvent {
+			continue
+		}
+
+		var h header
+		if i.err = unmarshalHeader(i.bucket.Get(encodeKey(ver, itemTypeHeader, "")), &h); i.err != nil {
+			return false
+		}
+
+		// values are only valid for the life of the transaction
+		i.event = h.event(etyp, ver, copyBytes(v))
+		return true
+	}
+
+	return false
+}
+
+// Event returns the current event
+func (i *iterator) Event() salsa.EncodedEvent {
+	return i.event
+}
+
+// Err returns the iteration error
+func (i *iterator) Err() error {
+	return i.err
+}
+
+// Close closes the iterator, releasing the read transaction
+func (i *iterator) Close() error {
+	if i.btx == nil {
+		return nil
+	}
+
+	btx := i.btx
+	i.btx = nil
+
+	return btx.Rollback()
+}
+
+func purgeStates(bu *bbolt.Bucket) error {
+	var ks [][]byte
+	err := bu.ForEach(func(k, _ []byte) error {
+		if _, ityp, _ := decodeKey(k); ityp == itemTypeState || ityp == itemTypeStateHeader {
+			ks = append(ks, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range ks {
+		if err = bu.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readPointer reads the event referenced by the specified encoded pointer
+func readPointer(btx *bbolt.Tx, b []byte) (salsa.StreamEvent[string], error) {
+	var p pointer
+	if err := json.Unmarshal(b, &p); err != nil {
+		return salsa.StreamEvent[string]{}, err
+	}
+
+	bu := btx.Bucket([]byte(p.ID))
+	if bu == nil {
+		return salsa.StreamEvent[string]{}, errors.New("invalid event pointer")
+	}
+
+	var h header
+	if err := unmarshalHeader(bu.Get(encodeKey(p.Version, itemTypeHeader, "")), &h); err != nil {
+		return salsa.StreamEvent[string]{}, err
+	}
+
+	return salsa.StreamEvent[string]{
+		ID:           p.ID,
+		EncodedEvent: h.event(p.Type, p.Version, copyBytes(bu.Get(encodeKey(p.Version, itemTypeEvent, p.Type)))),
+	}, nil
+}
+
+// unmarshalHeader unmarshals the header value, leaving the header unchanged if the value does not exist.
+// Stores written by earlier versions do not contain header items.
+func unmarshalHeader(b []byte, h any) error {
+	if b == nil {
+		return nil
+	}
+
+	return json.Unmarshal(b, h)
+}
+
+func isReserved(bucket []byte) bool {
+	n := string(bucket)
+	return n == string(allBucket) || n == string(checkpointBucket) || n == string(snapshotBucket) || n == string(outboxBucket)
+}
+
+func newHeader(m salsa.Metadata) header {
+	return header{
+		EventID:       m.EventID,
+		Timestamp:     m.Timestamp,
+		CorrelationID: m.CorrelationID,
+		CausationID:   m.CausationID,
+		Headers:       m.Headers,
+	}
+}
+
+func (h header) metadata() salsa.Metadata {
+	return salsa.Metadata{
+		EventID:       h.EventID,
+		Timestamp:     h.Timestamp,
+		CorrelationID: h.CorrelationID,
+		CausationID:   h.CausationID,
+		Headers:       h.Headers,
+	}
+}
+
+func (h header) event(etyp string, ver uint64, data []byte) salsa.EncodedEvent {
+	return salsa.EncodedEvent{
+		Type:     etyp,
+		Version:  ver,
+		Schema:   h.Schema,
+		Position: h.Position,
+		Data:     data,
+		Metadata: h.metadata(),
+	}
 }
 
 func encodeKey(v uint64, t itemType, st string) []byte {
@@ -119,6 +725,12 @@ func encodeKey(v uint64, t itemType, st string) []byte {
 	return b
 }
 
+func encodePosition(p uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, p)
+	return b
+}
+
 func decodeKey(b []byte) (uint64, itemType, string) {
 	v := binary.BigEndian.Uint64(b[:8])
 	t := itemType(b[8])
@@ -127,6 +739,12 @@ func decodeKey(b []byte) (uint64, itemType, string) {
 	return v, t, st
 }
 
+func copyBytes(b []byte) []byte {
+	res := make([]byte, len(b))
+	copy(res, b)
+	return res
+}
+
 func reverse[T any](s []T) {
 	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
 		s[i], s[j] = s[j], s[i]
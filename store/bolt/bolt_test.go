@@ -2,33 +2,25 @@ package bolt_test
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stevecallear/salsa"
+	"github.com/stevecallear/salsa/salsatest"
 	"go.etcd.io/bbolt"
 
 	"github.com/stevecallear/salsa/store/bolt"
 )
 
 func TestNew(t *testing.T) {
-	const fn = "bolt_test.db"
-
-	db, err := bbolt.Open(fn, 0666, nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer func() {
-		if err := db.Close(); err != nil {
-			t.Fatal(err)
-		}
-		if err := os.Remove(fn); err != nil {
-			t.Fatal(err)
-		}
-	}()
+	db, cleanup := openDB(t, "bolt_test.db")
+	defer cleanup()
 
 	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
 		return new(event), nil
@@ -39,7 +31,9 @@ func TestNew(t *testing.T) {
 	id := uuid.NewString()
 	t.Run("should return an error if the aggregate does not exist", func(t *testing.T) {
 		_, err := sut.Get(context.Background(), id)
-		assertErrorExists(t, err, true)
+		if !errors.Is(err, salsa.ErrNotFound) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrNotFound)
+		}
 	})
 
 	t.Run("should write the aggregate", func(t *testing.T) {
@@ -59,7 +53,28 @@ func TestNew(t *testing.T) {
 		assertErrorExists(t, err, false)
 
 		err = sut.Save(context.Background(), id, a)
-		assertErrorExists(t, err, true)
+
+		var cerr *salsa.ConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: id, Expected: 0, Actual: 12})
+	})
+
+	t.Run("should return an error if a new stream exists", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 100})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), id, a, salsa.WithNewStream())
+		if !errors.Is(err, salsa.ErrConflict) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrConflict)
+		}
+
+		ok, err := sut.Exists(context.Background(), id)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, ok, true)
 	})
 
 	t.Run("should read the aggregate (state)", func(t *testing.T) {
@@ -85,7 +100,11 @@ func TestNew(t *testing.T) {
 			assertErrorExists(t, err, false)
 		}
 
-		err = sut.Save(context.Background(), id, a)
+		ctx := salsa.ContextWithCorrelationID(context.Background(), "correlationid")
+		ctx = salsa.ContextWithCausationID(ctx, "causationid")
+		ctx = salsa.ContextWithHeaders(ctx, map[string]string{"key": "value"})
+
+		err = sut.Save(ctx, id, a)
 		assertErrorExists(t, err, false)
 	})
 
@@ -101,9 +120,253 @@ func TestNew(t *testing.T) {
 				Current: 14,
 			},
 		})
+
+		ms := act.Metadata()
+		if act, exp := len(ms), 2; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for _, m := range ms {
+			if m.EventID == "" || m.Timestamp.IsZero() {
+				t.Errorf("got %v, expected event id and timestamp", m)
+			}
+
+			assertDeepEqual(t, []any{m.CorrelationID, m.CausationID, m.Headers},
+				[]any{"correlationid", "causationid", map[string]string{"key": "value"}})
+		}
+	})
+
+	t.Run("should read the global stream", func(t *testing.T) {
+		act, err := sut.ReadAll(context.Background(), 0, 0)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 14; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for i, e := range act {
+			assertDeepEqual(t, []any{e.ID, e.Version, e.Position}, []any{id, uint64(i + 1), uint64(i + 1)})
+		}
+
+		act, err = sut.ReadAll(context.Background(), 13, 1)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 1; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		assertDeepEqual(t, act[0].Event, &event{Amount: 10})
+		assertDeepEqual(t, act[0].Position, uint64(13))
+	})
+
+	t.Run("should purge snapshots", func(t *testing.T) {
+		err := sut.PurgeSnapshots(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 810},
+			versions: salsa.Versions{
+				State:   0,
+				Initial: 14,
+				Current: 14,
+			},
+		})
+
+		err = sut.PurgeAllSnapshots(context.Background())
+		assertErrorExists(t, err, false)
 	})
 }
 
+func TestNew_LegacyLayout(t *testing.T) {
+	db, cleanup := openDB(t, "bolt_legacy_test.db")
+	defer cleanup()
+
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := bolt.New(db, salsa.WithResolver[state](er))
+
+	// items are written without headers, as they were before metadata and state schemas were stored
+	key := func(v uint64, t byte, st string) []byte {
+		b := make([]byte, 9, 9+len(st))
+		binary.BigEndian.PutUint64(b, v)
+		b[8] = t
+		return append(b, st...)
+	}
+
+	seed := func(id string, state uint64) {
+		err := db.Update(func(btx *bbolt.Tx) error {
+			bu, err := btx.CreateBucket([]byte(id))
+			if err != nil {
+				return err
+			}
+
+			for v := uint64(1); v <= 3; v++ {
+				if err = bu.Put(key(v, 1, "event"), []byte(`{"amount":10}`)); err != nil {
+					return err
+				}
+
+				if v == state {
+					if err = bu.Put(key(v, 2, ""), []byte(fmt.Sprintf(`{"balance":%d}`, v*10))); err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		state uint64
+		exp   aggregate
+	}{
+		{
+			name:  "should read events without headers",
+			state: 0,
+			exp: aggregate{
+				state:    state{Balance: 30},
+				versions: salsa.Versions{Initial: 3, Current: 3},
+			},
+		},
+		{
+			name:  "should read states without headers",
+			state: 2,
+			exp: aggregate{
+				state:    state{Balance: 30},
+				versions: salsa.Versions{State: 2, Initial: 3, Current: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := uuid.NewString()
+			seed(id, tt.state)
+
+			act, err := sut.Get(context.Background(), id)
+			assertErrorExists(t, err, false)
+			assertAggregateEqual(t, act, tt.exp)
+
+			_, err = act.Apply(&event{Amount: 10})
+			assertErrorExists(t, err, false)
+
+			err = sut.Save(context.Background(), id, act)
+			assertErrorExists(t, err, false)
+
+			act, err = sut.Get(context.Background(), id)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act.State(), state{Balance: 40})
+		})
+	}
+}
+
+func TestNewDB(t *testing.T) {
+	db, cleanup := openDB(t, "bolt_db_test.db")
+	defer cleanup()
+
+	salsatest.RunDBSuite(t, func() salsa.DB[string] {
+		return bolt.NewDB(db)
+	})
+}
+
+func TestNewSnapshotStore(t *testing.T) {
+	db, cleanup := openDB(t, "bolt_snapshot_test.db")
+	defer cleanup()
+
+	salsatest.RunSnapshotStoreSuite(t, func() salsa.SnapshotStore[string] {
+		return bolt.NewSnapshotStore(db)
+	})
+}
+
+func TestNewCheckpointStore(t *testing.T) {
+	db, cleanup := openDB(t, "bolt_checkpoint_test.db")
+	defer cleanup()
+
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	str := bolt.New(db, salsa.WithResolver[state](er))
+	sut := bolt.NewCheckpointStore(db)
+
+	t.Run("should return zero if no checkpoint exists", func(t *testing.T) {
+		act, err := sut.Load(context.Background(), "sub")
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act, uint64(0))
+	})
+
+	t.Run("should save the checkpoint", func(t *testing.T) {
+		err := sut.Save(context.Background(), "sub", 10)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.Load(context.Background(), "sub")
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act, uint64(10))
+
+		err = sut.Save(context.Background(), "sub", 0)
+		assertErrorExists(t, err, false)
+	})
+
+	t.Run("should run a subscription from the checkpoint", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		for i := 1; i <= 3; i++ {
+			_, err := a.Apply(&event{Amount: i})
+			assertErrorExists(t, err, false)
+		}
+
+		err := str.Save(context.Background(), uuid.NewString(), a)
+		assertErrorExists(t, err, false)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var act []int
+		sub := salsa.NewSubscription(str, "sub", func(ctx context.Context, e salsa.RecordedEvent[string, state]) error {
+			act = append(act, e.Event.(*event).Amount)
+			if len(act) >= 3 {
+				cancel()
+			}
+			return nil
+		}, salsa.WithPollInterval(time.Millisecond), salsa.WithCheckpointStore(sut))
+
+		err = sub.Run(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, expected %v", err, context.Canceled)
+		}
+
+		assertDeepEqual(t, act, []int{1, 2, 3})
+
+		pos, err := sut.Load(context.Background(), "sub")
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, pos, uint64(3))
+	})
+}
+
+func openDB(t *testing.T, fn string) (*bbolt.DB, func()) {
+	db, err := bbolt.Open(fn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db, func() {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 type (
 	state struct {
 		Balance int `json:"balance"`
@@ -149,3 +412,9 @@ func assertAggregateEqual(t *testing.T, act *salsa.Aggregate[state], exp aggrega
 		t.Errorf("got %v, expected %v", act, exp)
 	}
 }
+
+func assertDeepEqual(t *testing.T, act, exp interface{}) {
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
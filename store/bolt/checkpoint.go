@@ -0,0 +1,53 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/stevecallear/salsa"
+)
+
+type checkpointStore struct {
+	bdb *bbolt.DB
+}
+
+// checkpointBucket is the name of the bucket containing subscription checkpoints.
+// It must not be used as an aggregate id.
+var checkpointBucket = []byte("$checkpoints")
+
+// NewCheckpointStore returns a new subscription checkpoint store backed by boltdb
+func NewCheckpointStore(bdb *bbolt.DB) salsa.CheckpointStore {
+	return &checkpointStore{bdb: bdb}
+}
+
+// Load returns the checkpoint position for the specified subscription
+func (s *checkpointStore) Load(ctx context.Context, name string) (uint64, error) {
+	var pos uint64
+	err := s.bdb.View(func(btx *bbolt.Tx) error {
+		bu := btx.Bucket(checkpointBucket)
+		if bu == nil {
+			return nil
+		}
+
+		if b := bu.Get([]byte(name)); b != nil {
+			pos = binary.BigEndian.Uint64(b)
+		}
+		return nil
+	})
+
+	return pos, err
+}
+
+// Save saves the checkpoint position for the specified subscription
+func (s *checkpointStore) Save(ctx context.Context, name string, position uint64) error {
+	return s.bdb.Update(func(btx *bbolt.Tx) error {
+		bu, err := btx.CreateBucketIfNotExists(checkpointBucket)
+		if err != nil {
+			return err
+		}
+
+		return bu.Put([]byte(name), encodePosition(position))
+	})
+}
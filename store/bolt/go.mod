@@ -9,3 +9,5 @@ require (
 )
 
 require golang.org/x/sys v0.0.0-20200923182605-d9f96fdee20d // indirect
+
+replace github.com/stevecallear/salsa => ../..
@@ -0,0 +1,136 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/stevecallear/salsa"
+)
+
+type (
+	snapshotStore struct {
+		bdb *bbolt.DB
+	}
+
+	snapshot struct {
+		Schema uint   `json:"schema,omitempty"`
+		Data   []byte `json:"data"`
+	}
+)
+
+// snapshotBucket is the name of the bucket containing snapshot store states, with a nested bucket per id.
+// It must not be used as an aggregate id.
+var snapshotBucket = []byte("$snapshots")
+
+// NewSnapshotStore returns a new snapshot store backed by boltdb
+func NewSnapshotStore(bdb *bbolt.DB) salsa.SnapshotStore[string] {
+	return &snapshotStore{bdb: bdb}
+}
+
+// ReadState reads the most recent state at or below the specified version.
+// A zero version reads the latest state.
+func (s *snapshotStore) ReadState(ctx context.Context, id string, version uint64) (salsa.EncodedState, error) {
+	var state salsa.EncodedState
+	err := s.bdb.View(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		bu := btx.Bucket(snapshotBucket)
+		if bu == nil {
+			return nil
+		}
+
+		if bu = bu.Bucket([]byte(id)); bu == nil {
+			return nil
+		}
+
+		// keys are ordered by big-endian version, so seek past the version and read backwards
+		c := bu.Cursor()
+		k, v := c.Last()
+		if version > 0 {
+			if k, _ = c.Seek(encodePosition(version + 1)); k != nil {
+				k, v = c.Prev()
+			} else {
+				k, v = c.Last()
+			}
+		}
+
+		if k == nil {
+			return nil
+		}
+
+		var sn snapshot
+		if err := json.Unmarshal(v, &sn); err != nil {
+			return err
+		}
+
+		state = salsa.EncodedState{
+			Version: binary.BigEndian.Uint64(k),
+			Schema:  sn.Schema,
+			Data:    sn.Data,
+		}
+		return nil
+	})
+
+	return state, err
+}
+
+// WriteState writes the specified state, replacing any existing state with the same version
+func (s *snapshotStore) WriteState(ctx context.Context, id string, st salsa.EncodedState) error {
+	b, err := json.Marshal(snapshot{Schema: st.Schema, Data: st.Data})
+	if err != nil {
+		return err
+	}
+
+	return s.bdb.Update(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		bu, err := btx.CreateBucketIfNotExists(snapshotBucket)
+		if err != nil {
+			return err
+		}
+
+		if bu, err = bu.CreateBucketIfNotExists([]byte(id)); err != nil {
+			return err
+		}
+
+		return bu.Put(encodePosition(st.Version), b)
+	})
+}
+
+// PurgeStates deletes all states for the specified id
+func (s *snapshotStore) PurgeStates(ctx context.Context, id string) error {
+	return s.bdb.Update(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		bu := btx.Bucket(snapshotBucket)
+		if bu == nil || bu.Bucket([]byte(id)) == nil {
+			return nil
+		}
+
+		return bu.DeleteBucket([]byte(id))
+	})
+}
+
+// PurgeAllStates deletes all states for all ids
+func (s *snapshotStore) PurgeAllStates(ctx context.Context) error {
+	return s.bdb.Update(func(btx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if btx.Bucket(snapshotBucket) == nil {
+			return nil
+		}
+
+		return btx.DeleteBucket(snapshotBucket)
+	})
+}
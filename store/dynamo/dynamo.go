@@ -3,7 +3,9 @@ package dynamo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -18,14 +20,38 @@ type (
 		client    *dynamodb.Client
 	}
 
+	iterator struct {
+		ctx   context.Context
+		db    *db
+		in    *dynamodb.QueryInput
+		items []map[string]types.AttributeValue
+		event salsa.EncodedEvent
+		err   error
+		done  bool
+	}
+
 	tx struct {
 		tableName string
 		id        string
-		input     *dynamodb.TransactWriteItemsInput
+		expected  *uint64
+		events    []salsa.EncodedEvent
+		states    []salsa.EncodedState
+		outbox    []int
 	}
 )
 
-const stateType = "STATE"
+const (
+	stateType = "STATE"
+
+	// globalKey is the partition key of the global event stream
+	globalKey = "G#$all"
+
+	// outboxKey is the partition key of the transactional outbox
+	outboxKey = "O#$outbox"
+
+	maxPositionAttempts = 10
+	maxTransactItems    = 100
+)
 
 // CreateTable creates the required dynamodb table for the event store
 func CreateTable(ctx context.Context, c *dynamodb.Client, tableName string) error {
@@ -66,43 +92,36 @@ func CreateTable(ctx context.Context, c *dynamodb.Client, tableName string) erro
 
 // New returns a new event store backed by dynamodb
 func New[T any](c *dynamodb.Client, tableName string, optFns ...func(*salsa.Options[T])) *salsa.Store[string, T] {
-	return salsa.NewStore[string](&db{
+	return salsa.NewStore[string](NewDB(c, tableName), optFns...)
+}
+
+// NewDB returns a new events DB backed by dynamodb
+func NewDB(c *dynamodb.Client, tableName string) salsa.DB[string] {
+	return &db{
 		tableName: tableName,
 		client:    c,
-	}, optFns...)
+	}
+}
+
+// NewSnapshotStore returns a new snapshot store backed by dynamodb
+func NewSnapshotStore(c *dynamodb.Client, tableName string) salsa.SnapshotStore[string] {
+	return &db{
+		tableName: tableName,
+		client:    c,
+	}
 }
 
 // Read reads most recent state and events for the specified id
 func (d *db) Read(ctx context.Context, id string) (salsa.EncodedState, []salsa.EncodedEvent, error) {
-	res, err := d.client.Query(ctx, &dynamodb.QueryInput{
-		TableName:              aws.String(d.tableName),
-		KeyConditionExpression: aws.String("#pk = :pk"),
-		ExpressionAttributeNames: map[string]string{
-			"#pk": "pk",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk": &types.AttributeValueMemberS{Value: stateKey(id)},
-		},
-		ScanIndexForward: aws.Bool(false),
-		ConsistentRead:   aws.Bool(true),
-		Limit:            aws.Int32(int32(1)),
-	})
+	state, err := d.readState(ctx, id, 0)
 	if err != nil {
 		return salsa.EncodedState{}, nil, err
 	}
 
-	var state salsa.EncodedState
-	if len(res.Items) > 0 {
-		state, err = d.avToState(res.Items[0])
-		if err != nil {
-			return salsa.EncodedState{}, nil, err
-		}
-	}
-
 	var events []salsa.EncodedEvent
 	var lastKey map[string]types.AttributeValue
 	for {
-		res, err = d.client.Query(ctx, &dynamodb.QueryInput{
+		res, err := d.client.Query(ctx, &dynamodb.QueryInput{
 			TableName:              aws.String(d.tableName),
 			KeyConditionExpression: aws.String("#pk = :pk and #v > :v"),
 			ExpressionAttributeNames: map[string]string{
@@ -137,33 +156,552 @@ func (d *db) Read(ctx context.Context, id string) (salsa.EncodedState, []salsa.E
 	}
 
 	if state.Version == 0 && len(events) < 1 {
-		return salsa.EncodedState{}, nil, errors.New("not found")
+		return salsa.EncodedState{}, nil, salsa.ErrNotFound
 	}
 
 	reverse(events)
 	return state, events, nil
 }
 
-// Write executes the specified write function within a transaction
-func (d *db) Write(ctx context.Context, id string, fn func(salsa.DBTx) error) error {
-	in := &dynamodb.TransactWriteItemsInput{
-		TransactItems: []types.TransactWriteItem{},
+// ReadAt reads the most recent state at or below the version and the subsequent events up to the version
+func (d *db) ReadAt(ctx context.Context, id string, version uint64) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	state, err := d.readState(ctx, id, version)
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
 	}
 
+	if state.Version > 0 && state.Version == version {
+		return state, nil, nil
+	}
+
+	events, err := d.ReadEvents(ctx, id, state.Version+1, version)
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	return state, events, nil
+}
+
+// ReadVersion reads the current version for the specified id
+func (d *db) ReadVersion(ctx context.Context, id string) (uint64, error) {
+	v, err := d.version(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if v == 0 {
+		return 0, salsa.ErrNotFound
+	}
+
+	return v, nil
+}
+
+// ReadIter reads the most recent state and returns an iterator over subsequent events for the specified id.
+// Events are queried a page at a time as the iterator advances.
+func (d *db) ReadIter(ctx context.Context, id string) (salsa.EncodedState, salsa.EventIterator, error) {
+	if err := ctx.Err(); err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	state, err := d.readState(ctx, id, 0)
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	return state, &iterator{
+		ctx: ctx,
+		db:  d,
+		in: &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("#pk = :pk and #v > :v"),
+			ExpressionAttributeNames: map[string]string{
+				"#pk": "pk",
+				"#v":  "version",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: eventKey(id)},
+				":v":  &types.AttributeValueMemberN{Value: strconv.FormatUint(state.Version, 10)},
+			},
+			ScanIndexForward: aws.Bool(true),
+			ConsistentRead:   aws.Bool(true),
+		},
+	}, nil
+}
+
+// ReadEvents reads the events for the specified id within the version range.
+// A zero to version reads all events from the specified version.
+func (d *db) ReadEvents(ctx context.Context, id string, from, to uint64) ([]salsa.EncodedEvent, error) {
+	kce := "#pk = :pk and #v >= :from"
+	eav := map[string]types.AttributeValue{
+		":pk":   &types.AttributeValueMemberS{Value: eventKey(id)},
+		":from": &types.AttributeValueMemberN{Value: strconv.FormatUint(from, 10)},
+	}
+
+	if to > 0 {
+		kce = "#pk = :pk and #v between :from and :to"
+		eav[":to"] = &types.AttributeValueMemberN{Value: strconv.FormatUint(to, 10)}
+	}
+
+	var events []salsa.EncodedEvent
+	var lastKey map[string]types.AttributeValue
+	for {
+		res, err := d.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String(kce),
+			ExpressionAttributeNames: map[string]string{
+				"#pk": "pk",
+				"#v":  "version",
+			},
+			ExpressionAttributeValues: eav,
+			ScanIndexForward:          aws.Bool(true),
+			ConsistentRead:            aws.Bool(true),
+			ExclusiveStartKey:         lastKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, itm := range res.Items {
+			e, err := d.avToEvent(itm)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, e)
+		}
+
+		if res.LastEvaluatedKey == nil {
+			break
+		}
+
+		lastKey = res.LastEvaluatedKey
+	}
+
+	if len(events) < 1 {
+		v, err := d.version(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if v == 0 {
+			return nil, salsa.ErrNotFound
+		}
+	}
+
+	return events, nil
+}
+
+// Write executes the specified write function within a transaction
+func (d *db) Write(ctx context.Context, id string, fn func(salsa.DBTx) error) error {
 	t := &tx{
 		tableName: d.tableName,
 		id:        id,
-		input:     in,
 	}
 
 	if err := fn(t); err != nil {
 		return err
 	}
 
-	_, err := d.client.TransactWriteItems(ctx, in)
+	if t.expected == nil {
+		return nil
+	}
+
+	return d.write(ctx, []*tx{t})
+}
+
+// WriteMulti executes the specified write function for each id within a single transaction.
+// DynamoDB transactions are limited to 100 items, including the global stream items.
+func (d *db) WriteMulti(ctx context.Context, ids []string, fn func(string, salsa.DBTx) error) error {
+	ts := make([]*tx, 0, len(ids))
+	for _, id := range ids {
+		t := &tx{
+			tableName: d.tableName,
+			id:        id,
+		}
+
+		if err := fn(id, t); err != nil {
+			return err
+		}
+
+		if t.expected != nil {
+			ts = append(ts, t)
+		}
+	}
+
+	if len(ts) < 1 {
+		return nil
+	}
+
+	return d.write(ctx, ts)
+}
+
+// ReadAll returns up to limit events from the global stream, starting at the specified position
+func (d *db) ReadAll(ctx context.Context, from uint64, limit int) ([]salsa.StreamEvent[string], error) {
+	var events []salsa.StreamEvent[string]
+	var lastKey map[string]types.AttributeValue
+	for {
+		in := &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("#pk = :pk and #v >= :v"),
+			ExpressionAttributeNames: map[string]string{
+				"#pk": "pk",
+				"#v":  "version",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: globalKey},
+				":v":  &types.AttributeValueMemberN{Value: strconv.FormatUint(from, 10)},
+			},
+			ScanIndexForward:  aws.Bool(true),
+			ConsistentRead:    aws.Bool(true),
+			ExclusiveStartKey: lastKey,
+		}
+
+		if limit > 0 {
+			in.Limit = aws.Int32(int32(limit - len(events)))
+		}
+
+		res, err := d.client.Query(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, itm := range res.Items {
+			e, err := d.avToStreamEvent(itm)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, e)
+		}
+
+		if res.LastEvaluatedKey == nil || (limit > 0 && len(events) >= limit) {
+			break
+		}
+
+		lastKey = res.LastEvaluatedKey
+	}
+
+	return events, nil
+}
+
+// ReadState reads the most recent state at or below the specified version.
+// A zero version reads the latest state.
+func (d *db) ReadState(ctx context.Context, id string, version uint64) (salsa.EncodedState, error) {
+	if err := ctx.Err(); err != nil {
+		return salsa.EncodedState{}, err
+	}
+
+	return d.readState(ctx, id, version)
+}
+
+// WriteState writes the specified state, replacing any existing state with the same version
+func (d *db) WriteState(ctx context.Context, id string, s salsa.EncodedState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName),
+		Item:      (&tx{id: id}).stateToAV(s),
+	})
+
 	return err
 }
 
+// ReadOutbox returns up to limit outbox events in position order
+func (d *db) ReadOutbox(ctx context.Context, limit int) ([]salsa.StreamEvent[string], error) {
+	var events []salsa.StreamEvent[string]
+	var lastKey map[string]types.AttributeValue
+	for {
+		in := &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("#pk = :pk"),
+			ExpressionAttributeNames: map[string]string{
+				"#pk": "pk",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: outboxKey},
+			},
+			ScanIndexForward:  aws.Bool(true),
+			ConsistentRead:    aws.Bool(true),
+			ExclusiveStartKey: lastKey,
+		}
+
+		if limit > 0 {
+			in.Limit = aws.Int32(int32(limit - len(events)))
+		}
+
+		res, err := d.client.Query(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, itm := range res.Items {
+			e, err := d.avToStreamEvent(itm)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, e)
+		}
+
+		if res.LastEvaluatedKey == nil || (limit > 0 && len(events) >= limit) {
+			break
+		}
+
+		lastKey = res.LastEvaluatedKey
+	}
+
+	return events, nil
+}
+
+// DeleteOutbox deletes the outbox events with the specified positions
+func (d *db) DeleteOutbox(ctx context.Context, positions ...uint64) error {
+	keys := make([]map[string]types.AttributeValue, len(positions))
+	for i, p := range positions {
+		keys[i] = map[string]types.AttributeValue{
+			"pk":      &types.AttributeValueMemberS{Value: outboxKey},
+			"version": &types.AttributeValueMemberN{Value: strconv.FormatUint(p, 10)},
+		}
+	}
+
+	return d.deleteItems(ctx, keys)
+}
+
+// PurgeStates deletes all states for the specified id
+func (d *db) PurgeStates(ctx context.Context, id string) error {
+	var lastKey map[string]types.AttributeValue
+	for {
+		res, err := d.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("#pk = :pk"),
+			ExpressionAttributeNames: map[string]string{
+				"#pk": "pk",
+				"#v":  "version",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: stateKey(id)},
+			},
+			ProjectionExpression: aws.String("#pk, #v"),
+			ExclusiveStartKey:    lastKey,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = d.deleteItems(ctx, res.Items); err != nil {
+			return err
+		}
+
+		if res.LastEvaluatedKey == nil {
+			return nil
+		}
+
+		lastKey = res.LastEvaluatedKey
+	}
+}
+
+// PurgeAllStates deletes all states for all ids
+func (d *db) PurgeAllStates(ctx context.Context) error {
+	var lastKey map[string]types.AttributeValue
+	for {
+		res, err := d.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:        aws.String(d.tableName),
+			FilterExpression: aws.String("begins_with(#pk, :prefix)"),
+			ExpressionAttributeNames: map[string]string{
+				"#pk": "pk",
+				"#v":  "version",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":prefix": &types.AttributeValueMemberS{Value: stateKey("")},
+			},
+			ProjectionExpression: aws.String("#pk, #v"),
+			ExclusiveStartKey:    lastKey,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = d.deleteItems(ctx, res.Items); err != nil {
+			return err
+		}
+
+		if res.LastEvaluatedKey == nil {
+			return nil
+		}
+
+		lastKey = res.LastEvaluatedKey
+	}
+}
+
+func (d *db) deleteItems(ctx context.Context, keys []map[string]types.AttributeValue) error {
+	for _, k := range keys {
+		_, err := d.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(d.tableName),
+			Key:       k,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *db) write(ctx context.Context, ts []*tx) error {
+	var ne int
+	for _, t := range ts {
+		ne += len(t.events)
+	}
+
+	for i := 0; i < maxPositionAttempts; i++ {
+		var pos uint64
+		if ne > 0 {
+			var err error
+			if pos, err = d.position(ctx); err != nil {
+				return err
+			}
+		}
+
+		// aggregate items precede global stream items to allow conflicts to be identified
+		in := new(dynamodb.TransactWriteItemsInput)
+		var gis []types.TransactWriteItem
+		ends := make([]int, len(ts))
+		for j, t := range ts {
+			ais, tgis := t.items(pos)
+			pos += uint64(len(t.events))
+
+			in.TransactItems = append(in.TransactItems, ais...)
+			gis = append(gis, tgis...)
+			ends[j] = len(in.TransactItems)
+		}
+
+		if n := len(in.TransactItems) + len(gis); n > maxTransactItems {
+			return fmt.Errorf("transaction contains %d items, exceeding the maximum of %d", n, maxTransactItems)
+		}
+
+		in.TransactItems = append(in.TransactItems, gis...)
+		_, err := d.client.TransactWriteItems(ctx, in)
+
+		fcs := failedConditions(err)
+		if len(fcs) < 1 {
+			return err
+		}
+
+		for j, t := range ts {
+			if fcs[0] >= ends[j] {
+				continue
+			}
+
+			cerr := &salsa.ConflictError{ID: t.id, Expected: *t.expected}
+			if cerr.Actual, err = d.version(ctx, t.id); err != nil {
+				return err
+			}
+			return cerr
+		}
+	}
+
+	// the global stream is contended, so return a retryable conflict for the first aggregate with events
+	for _, t := range ts {
+		if len(t.events) < 1 {
+			continue
+		}
+
+		cerr := &salsa.ConflictError{ID: t.id, Expected: *t.expected}
+		var err error
+		if cerr.Actual, err = d.version(ctx, t.id); err != nil {
+			return err
+		}
+		return cerr
+	}
+
+	return nil
+}
+
+// readState reads the most recent state at or below the specified version.
+// A zero version reads the latest state.
+func (d *db) readState(ctx context.Context, id string, version uint64) (salsa.EncodedState, error) {
+	kce := "#pk = :pk"
+	ean := map[string]string{
+		"#pk": "pk",
+	}
+	eav := map[string]types.AttributeValue{
+		":pk": &types.AttributeValueMemberS{Value: stateKey(id)},
+	}
+
+	if version > 0 {
+		kce = "#pk = :pk and #v <= :v"
+		ean["#v"] = "version"
+		eav[":v"] = &types.AttributeValueMemberN{Value: strconv.FormatUint(version, 10)}
+	}
+
+	res, err := d.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(d.tableName),
+		KeyConditionExpression:    aws.String(kce),
+		ExpressionAttributeNames:  ean,
+		ExpressionAttributeValues: eav,
+		ScanIndexForward:          aws.Bool(false),
+		ConsistentRead:            aws.Bool(true),
+		Limit:                     aws.Int32(int32(1)),
+	})
+	if err != nil {
+		return salsa.EncodedState{}, err
+	}
+
+	var state salsa.EncodedState
+	if len(res.Items) > 0 {
+		state, err = d.avToState(res.Items[0])
+		if err != nil {
+			return salsa.EncodedState{}, err
+		}
+	}
+
+	return state, nil
+}
+
+func (d *db) position(ctx context.Context) (uint64, error) {
+	res, err := d.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "pk",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: globalKey},
+		},
+		ProjectionExpression: aws.String("version"),
+		ScanIndexForward:     aws.Bool(false),
+		ConsistentRead:       aws.Bool(true),
+		Limit:                aws.Int32(int32(1)),
+	})
+	if err != nil || len(res.Items) < 1 {
+		return 0, err
+	}
+
+	vm := res.Items[0]["version"].(*types.AttributeValueMemberN).Value
+	return strconv.ParseUint(vm, 10, 64)
+}
+
+func (d *db) version(ctx context.Context, id string) (uint64, error) {
+	res, err := d.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "pk",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: eventKey(id)},
+		},
+		ProjectionExpression: aws.String("version"),
+		ScanIndexForward:     aws.Bool(false),
+		ConsistentRead:       aws.Bool(true),
+		Limit:                aws.Int32(int32(1)),
+	})
+	if err != nil || len(res.Items) < 1 {
+		return 0, err
+	}
+
+	vm := res.Items[0]["version"].(*types.AttributeValueMemberN).Value
+	return strconv.ParseUint(vm, 10, 64)
+}
+
 func (db *db) avToState(av map[string]types.AttributeValue) (salsa.EncodedState, error) {
 	var vs salsa.EncodedState
 	var err error
@@ -177,7 +715,8 @@ func (db *db) avToState(av map[string]types.AttributeValue) (salsa.EncodedState,
 	dm := av["data"].(*types.AttributeValueMemberS).Value
 	vs.Data = []byte(dm)
 
-	return vs, nil
+	vs.Schema, err = avToSchema(av)
+	return vs, err
 }
 
 func (db *db) avToEvent(av map[string]types.AttributeValue) (salsa.EncodedEvent, error) {
@@ -195,33 +734,178 @@ func (db *db) avToEvent(av map[string]types.AttributeValue) (salsa.EncodedEvent,
 	dm := av["data"].(*types.AttributeValueMemberS).Value
 	e.Data = []byte(dm)
 
-	return e, nil
+	if e.Schema, err = avToSchema(av); err != nil {
+		return e, err
+	}
+
+	if pm, ok := av["position"].(*types.AttributeValueMemberN); ok {
+		e.Position, err = strconv.ParseUint(pm.Value, 10, 64)
+		if err != nil {
+			return e, err
+		}
+	}
+
+	e.Metadata, err = avToMetadata(av)
+	return e, err
+}
+
+func (db *db) avToStreamEvent(av map[string]types.AttributeValue) (salsa.StreamEvent[string], error) {
+	e, err := db.avToEvent(av)
+	if err != nil {
+		return salsa.StreamEvent[string]{}, err
+	}
+
+	e.Position = e.Version
+
+	vm := av["eventVersion"].(*types.AttributeValueMemberN).Value
+	e.Version, err = strconv.ParseUint(vm, 10, 64)
+	if err != nil {
+		return salsa.StreamEvent[string]{}, err
+	}
+
+	return salsa.StreamEvent[string]{
+		ID:           av["id"].(*types.AttributeValueMemberS).Value,
+		EncodedEvent: e,
+	}, nil
+}
+
+func avToSchema(av map[string]types.AttributeValue) (uint, error) {
+	sm, ok := av["schema"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseUint(sm.Value, 10, 64)
+	return uint(v), err
+}
+
+func avToMetadata(av map[string]types.AttributeValue) (salsa.Metadata, error) {
+	var m salsa.Metadata
+
+	if v, ok := av["eventId"].(*types.AttributeValueMemberS); ok {
+		m.EventID = v.Value
+	}
+
+	if v, ok := av["timestamp"].(*types.AttributeValueMemberS); ok {
+		ts, err := time.Parse(time.RFC3339Nano, v.Value)
+		if err != nil {
+			return m, err
+		}
+		m.Timestamp = ts
+	}
+
+	if v, ok := av["correlationId"].(*types.AttributeValueMemberS); ok {
+		m.CorrelationID = v.Value
+	}
+
+	if v, ok := av["causationId"].(*types.AttributeValueMemberS); ok {
+		m.CausationID = v.Value
+	}
+
+	if v, ok := av["headers"].(*types.AttributeValueMemberM); ok {
+		m.Headers = make(map[string]string, len(v.Value))
+		for k, hv := range v.Value {
+			if s, ok := hv.(*types.AttributeValueMemberS); ok {
+				m.Headers[k] = s.Value
+			}
+		}
+	}
+
+	return m, nil
 }
 
 // Event writes the specified event
 func (t *tx) Event(e salsa.EncodedEvent) error {
-	t.append(t.eventToAV(e))
+	t.expect(e.Version - 1)
+	t.events = append(t.events, e)
 	return nil
 }
 
+// Outbox writes the specified event to the outbox, keyed by the global position
+func (t *tx) Outbox(e salsa.EncodedEvent) error {
+	for i := range t.events {
+		if t.events[i].Version == e.Version {
+			t.outbox = append(t.outbox, i)
+			return nil
+		}
+	}
+
+	return errors.New("outbox event not written in transaction")
+}
+
 // State writes the specified state
 func (t *tx) State(s salsa.EncodedState) error {
-	t.append(t.stateToAV(s))
+	t.expect(s.Version)
+	t.states = append(t.states, s)
 	return nil
 }
 
-func (t *tx) append(av map[string]types.AttributeValue) {
-	t.input.TransactItems = append(t.input.TransactItems, types.TransactWriteItem{
-		Put: &types.Put{
-			TableName:           aws.String(t.tableName),
-			ConditionExpression: aws.String("(attribute_not_exists (#pk)) AND (attribute_not_exists (#v))"),
-			ExpressionAttributeNames: map[string]string{
-				"#pk": "pk",
-				"#v":  "version",
+func (t *tx) expect(v uint64) {
+	if t.expected == nil {
+		t.expected = &v
+	}
+}
+
+// items returns the aggregate and global stream transaction items, with events positioned
+// after the specified global position. Outbox items are returned with the global stream items.
+func (t *tx) items(pos uint64) ([]types.TransactWriteItem, []types.TransactWriteItem) {
+	ais := make([]types.TransactWriteItem, 0, len(t.events)+len(t.states)+1)
+
+	// ensure that the previous event exists to prevent gaps in the stream
+	if len(t.events) > 0 && t.events[0].Version > 1 {
+		ais = append(ais, types.TransactWriteItem{
+			ConditionCheck: &types.ConditionCheck{
+				TableName:           aws.String(t.tableName),
+				ConditionExpression: aws.String("attribute_exists (#pk)"),
+				ExpressionAttributeNames: map[string]string{
+					"#pk": "pk",
+				},
+				Key: map[string]types.AttributeValue{
+					"pk":      &types.AttributeValueMemberS{Value: eventKey(t.id)},
+					"version": &types.AttributeValueMemberN{Value: strconv.FormatUint(t.events[0].Version-1, 10)},
+				},
 			},
-			Item: av,
-		},
-	})
+		})
+	}
+
+	for i := range t.events {
+		t.events[i].Position = pos + uint64(i+1)
+		ais = append(ais, t.put(t.eventToAV(t.events[i]), true))
+	}
+
+	// states are derived from events, so can be overwritten
+	for _, s := range t.states {
+		ais = append(ais, t.put(t.stateToAV(s), false))
+	}
+
+	gis := make([]types.TransactWriteItem, len(t.events), len(t.events)+len(t.outbox))
+	for i, e := range t.events {
+		gis[i] = t.put(t.globalToAV(e), true)
+	}
+
+	// outbox items are unique as the global stream items are conditional
+	for _, i := range t.outbox {
+		gis = append(gis, t.put(t.outboxToAV(t.events[i]), false))
+	}
+
+	return ais, gis
+}
+
+func (t *tx) put(av map[string]types.AttributeValue, conditional bool) types.TransactWriteItem {
+	p := &types.Put{
+		TableName: aws.String(t.tableName),
+		Item:      av,
+	}
+
+	if conditional {
+		p.ConditionExpression = aws.String("(attribute_not_exists (#pk)) AND (attribute_not_exists (#v))")
+		p.ExpressionAttributeNames = map[string]string{
+			"#pk": "pk",
+			"#v":  "version",
+		}
+	}
+
+	return types.TransactWriteItem{Put: p}
 }
 
 func (t *tx) stateToAV(s salsa.EncodedState) map[string]types.AttributeValue {
@@ -230,18 +914,104 @@ func (t *tx) stateToAV(s salsa.EncodedState) map[string]types.AttributeValue {
 		"pk":      &types.AttributeValueMemberS{Value: stateKey(t.id)},
 		"version": &types.AttributeValueMemberN{Value: ve},
 		"type":    &types.AttributeValueMemberS{Value: stateType},
+		"schema":  &types.AttributeValueMemberN{Value: strconv.FormatUint(uint64(s.Schema), 10)},
 		"data":    &types.AttributeValueMemberS{Value: string(s.Data)},
 	}
 }
 
 func (t *tx) eventToAV(e salsa.EncodedEvent) map[string]types.AttributeValue {
 	ve := strconv.FormatUint(e.Version, 10)
-	return map[string]types.AttributeValue{
-		"pk":      &types.AttributeValueMemberS{Value: eventKey(t.id)},
-		"version": &types.AttributeValueMemberN{Value: ve},
-		"type":    &types.AttributeValueMemberS{Value: e.Type},
-		"data":    &types.AttributeValueMemberS{Value: string(e.Data)},
+	av := map[string]types.AttributeValue{
+		"pk":        &types.AttributeValueMemberS{Value: eventKey(t.id)},
+		"version":   &types.AttributeValueMemberN{Value: ve},
+		"type":      &types.AttributeValueMemberS{Value: e.Type},
+		"schema":    &types.AttributeValueMemberN{Value: strconv.FormatUint(uint64(e.Schema), 10)},
+		"data":      &types.AttributeValueMemberS{Value: string(e.Data)},
+		"position":  &types.AttributeValueMemberN{Value: strconv.FormatUint(e.Position, 10)},
+		"timestamp": &types.AttributeValueMemberS{Value: e.Metadata.Timestamp.Format(time.RFC3339Nano)},
+	}
+
+	if e.Metadata.EventID != "" {
+		av["eventId"] = &types.AttributeValueMemberS{Value: e.Metadata.EventID}
+	}
+
+	if e.Metadata.CorrelationID != "" {
+		av["correlationId"] = &types.AttributeValueMemberS{Value: e.Metadata.CorrelationID}
+	}
+
+	if e.Metadata.CausationID != "" {
+		av["causationId"] = &types.AttributeValueMemberS{Value: e.Metadata.CausationID}
+	}
+
+	if len(e.Metadata.Headers) > 0 {
+		hm := make(map[string]types.AttributeValue, len(e.Metadata.Headers))
+		for k, v := range e.Metadata.Headers {
+			hm[k] = &types.AttributeValueMemberS{Value: v}
+		}
+		av["headers"] = &types.AttributeValueMemberM{Value: hm}
+	}
+
+	return av
+}
+
+func (t *tx) globalToAV(e salsa.EncodedEvent) map[string]types.AttributeValue {
+	av := t.eventToAV(e)
+	av["pk"] = &types.AttributeValueMemberS{Value: globalKey}
+	av["version"] = &types.AttributeValueMemberN{Value: strconv.FormatUint(e.Position, 10)}
+	av["id"] = &types.AttributeValueMemberS{Value: t.id}
+	av["eventVersion"] = &types.AttributeValueMemberN{Value: strconv.FormatUint(e.Version, 10)}
+	delete(av, "position")
+
+	return av
+}
+
+func (t *tx) outboxToAV(e salsa.EncodedEvent) map[string]types.AttributeValue {
+	av := t.globalToAV(e)
+	av["pk"] = &types.AttributeValueMemberS{Value: outboxKey}
+
+	return av
+}
+
+// Next advances the iterator to the next event, querying the next page of events if required
+func (i *iterator) Next() bool {
+	for len(i.items) < 1 {
+		if i.done || i.err != nil {
+			return false
+		}
+
+		var res *dynamodb.QueryOutput
+		if res, i.err = i.db.client.Query(i.ctx, i.in); i.err != nil {
+			return false
+		}
+
+		i.items = res.Items
+		i.in.ExclusiveStartKey = res.LastEvaluatedKey
+		i.done = res.LastEvaluatedKey == nil
 	}
+
+	if i.event, i.err = i.db.avToEvent(i.items[0]); i.err != nil {
+		return false
+	}
+
+	i.items = i.items[1:]
+	return true
+}
+
+// Event returns the current event
+func (i *iterator) Event() salsa.EncodedEvent {
+	return i.event
+}
+
+// Err returns the iteration error
+func (i *iterator) Err() error {
+	return i.err
+}
+
+// Close closes the iterator
+func (i *iterator) Close() error {
+	i.items = nil
+	i.done = true
+	return nil
 }
 
 func stateKey(id string) string {
@@ -254,6 +1024,24 @@ func eventKey(id string) string {
 	return eventPrefix + id
 }
 
+// failedConditions returns the indexes of any transaction items that failed condition checks
+// or conflicted with a concurrent transaction
+func failedConditions(err error) []int {
+	var terr *types.TransactionCanceledException
+	if !errors.As(err, &terr) {
+		return nil
+	}
+
+	var res []int
+	for i, r := range terr.CancellationReasons {
+		if c := aws.ToString(r.Code); c == "ConditionalCheckFailed" || c == "TransactionConflict" {
+			res = append(res, i)
+		}
+	}
+
+	return res
+}
+
 func reverse[T any](s []T) {
 	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
 		s[i], s[j] = s[j], s[i]
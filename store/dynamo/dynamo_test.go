@@ -15,12 +15,13 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/stevecallear/salsa"
+	"github.com/stevecallear/salsa/salsatest"
 	"github.com/stevecallear/salsa/store/dynamo"
 )
 
 func TestMain(m *testing.M) {
 	client = newLocalClient()
-	for _, tn := range []string{testCreateTableName, testNewName} {
+	for _, tn := range []string{testCreateTableName, testNewName, testNewDBName, testNewSnapshotStoreName} {
 		_, err := client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{
 			TableName: aws.String(tn),
 		})
@@ -36,8 +37,10 @@ func TestMain(m *testing.M) {
 }
 
 const (
-	testCreateTableName = "salsa-testcreatetable"
-	testNewName         = "salsa-testnew"
+	testCreateTableName      = "salsa-testcreatetable"
+	testNewName              = "salsa-testnew"
+	testNewDBName            = "salsa-testnewdb"
+	testNewSnapshotStoreName = "salsa-testnewsnapshotstore"
 )
 
 var client *dynamodb.Client
@@ -83,7 +86,9 @@ func TestNew(t *testing.T) {
 	id := uuid.NewString()
 	t.Run("should return an error if the aggregate does not exist", func(t *testing.T) {
 		_, err := sut.Get(context.Background(), id)
-		assertErrorExists(t, err, true)
+		if !errors.Is(err, salsa.ErrNotFound) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrNotFound)
+		}
 	})
 
 	t.Run("should write the aggregate", func(t *testing.T) {
@@ -103,7 +108,28 @@ func TestNew(t *testing.T) {
 		assertErrorExists(t, err, false)
 
 		err = sut.Save(context.Background(), id, a)
-		assertErrorExists(t, err, true)
+
+		var cerr *salsa.ConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: id, Expected: 0, Actual: 12})
+	})
+
+	t.Run("should return an error if a new stream exists", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 100})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), id, a, salsa.WithNewStream())
+		if !errors.Is(err, salsa.ErrConflict) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrConflict)
+		}
+
+		ok, err := sut.Exists(context.Background(), id)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, ok, true)
 	})
 
 	t.Run("should read the aggregate (state)", func(t *testing.T) {
@@ -129,7 +155,11 @@ func TestNew(t *testing.T) {
 			assertErrorExists(t, err, false)
 		}
 
-		err = sut.Save(context.Background(), id, a)
+		ctx := salsa.ContextWithCorrelationID(context.Background(), "correlationid")
+		ctx = salsa.ContextWithCausationID(ctx, "causationid")
+		ctx = salsa.ContextWithHeaders(ctx, map[string]string{"key": "value"})
+
+		err = sut.Save(ctx, id, a)
 		assertErrorExists(t, err, false)
 	})
 
@@ -145,6 +175,83 @@ func TestNew(t *testing.T) {
 				Current: 14,
 			},
 		})
+
+		ms := act.Metadata()
+		if act, exp := len(ms), 2; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for _, m := range ms {
+			if m.EventID == "" || m.Timestamp.IsZero() {
+				t.Errorf("got %v, expected event id and timestamp", m)
+			}
+
+			assertDeepEqual(t, []any{m.CorrelationID, m.CausationID, m.Headers},
+				[]any{"correlationid", "causationid", map[string]string{"key": "value"}})
+		}
+	})
+
+	t.Run("should read the global stream", func(t *testing.T) {
+		act, err := sut.ReadAll(context.Background(), 0, 0)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 14; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for i, e := range act {
+			assertDeepEqual(t, []any{e.ID, e.Version, e.Position}, []any{id, uint64(i + 1), uint64(i + 1)})
+		}
+
+		act, err = sut.ReadAll(context.Background(), 13, 1)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 1; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		assertDeepEqual(t, act[0].Event, &event{Amount: 10})
+		assertDeepEqual(t, act[0].Position, uint64(13))
+	})
+
+	t.Run("should purge snapshots", func(t *testing.T) {
+		err := sut.PurgeSnapshots(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 810},
+			versions: salsa.Versions{
+				State:   0,
+				Initial: 14,
+				Current: 14,
+			},
+		})
+
+		err = sut.PurgeAllSnapshots(context.Background())
+		assertErrorExists(t, err, false)
+	})
+}
+
+func TestNewDB(t *testing.T) {
+	if err := dynamo.CreateTable(context.Background(), client, testNewDBName); err != nil {
+		t.Fatal(err)
+	}
+
+	salsatest.RunDBSuite(t, func() salsa.DB[string] {
+		return dynamo.NewDB(client, testNewDBName)
+	})
+}
+
+func TestNewSnapshotStore(t *testing.T) {
+	if err := dynamo.CreateTable(context.Background(), client, testNewSnapshotStoreName); err != nil {
+		t.Fatal(err)
+	}
+
+	salsatest.RunSnapshotStoreSuite(t, func() salsa.SnapshotStore[string] {
+		return dynamo.NewSnapshotStore(client, testNewSnapshotStoreName)
 	})
 }
 
@@ -211,3 +318,9 @@ func assertAggregateEqual(t *testing.T, act *salsa.Aggregate[state], exp aggrega
 		t.Errorf("got %v, expected %v", act, exp)
 	}
 }
+
+func assertDeepEqual(t *testing.T, act, exp interface{}) {
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
@@ -24,3 +24,5 @@ require (
 	github.com/aws/smithy-go v1.11.2 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 )
+
+replace github.com/stevecallear/salsa => ../..
@@ -0,0 +1,558 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/stevecallear/salsa"
+)
+
+type (
+	db struct {
+		pool   *pgxpool.Pool
+		tables tables
+	}
+
+	tx struct {
+		ctx      context.Context
+		id       string
+		ptx      pgx.Tx
+		tables   tables
+		version  *uint64
+		position uint64
+		locked   bool
+	}
+
+	iterator struct {
+		ctx   context.Context
+		ptx   pgx.Tx
+		rows  pgx.Rows
+		event salsa.EncodedEvent
+		err   error
+	}
+
+	tables struct {
+		name      string
+		events    string
+		snapshots string
+	}
+)
+
+// uniqueViolation is the postgres error code returned when a unique constraint is violated
+const uniqueViolation = "23505"
+
+// CreateTables creates the required postgres tables for the event store. Events are stored in
+// the specified table, with snapshots stored in a table of the same name with a _snapshots suffix.
+func CreateTables(ctx context.Context, p *pgxpool.Pool, tableName string) error {
+	t := newTables(tableName)
+
+	_, err := p.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			position BIGINT PRIMARY KEY,
+			stream_id TEXT NOT NULL,
+			version BIGINT NOT NULL,
+			type TEXT NOT NULL,
+			schema INTEGER NOT NULL DEFAULT 0,
+			data BYTEA NOT NULL,
+			event_id TEXT NOT NULL DEFAULT '',
+			timestamp TIMESTAMPTZ NOT NULL,
+			correlation_id TEXT NOT NULL DEFAULT '',
+			causation_id TEXT NOT NULL DEFAULT '',
+			headers JSONB,
+			UNIQUE (stream_id, version)
+		);
+		CREATE TABLE IF NOT EXISTS %s (
+			stream_id TEXT NOT NULL,
+			version BIGINT NOT NULL,
+			schema INTEGER NOT NULL DEFAULT 0,
+			data BYTEA NOT NULL,
+			PRIMARY KEY (stream_id, version)
+		);`, t.events, t.snapshots))
+
+	return err
+}
+
+// New returns a new event store backed by postgres
+func New[T any](p *pgxpool.Pool, tableName string, optFns ...func(*salsa.Options[T])) *salsa.Store[string, T] {
+	return salsa.NewStore[string](NewDB(p, tableName), optFns...)
+}
+
+// NewDB returns a new events DB backed by postgres
+func NewDB(p *pgxpool.Pool, tableName string) salsa.DB[string] {
+	return &db{
+		pool:   p,
+		tables: newTables(tableName),
+	}
+}
+
+// NewSnapshotStore returns a new snapshot store backed by postgres, using the snapshots table for the specified table name
+func NewSnapshotStore(p *pgxpool.Pool, tableName string) salsa.SnapshotStore[string] {
+	return &db{
+		pool:   p,
+		tables: newTables(tableName),
+	}
+}
+
+// Read reads most recent state and events for the specified id
+func (d *db) Read(ctx context.Context, id string) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	return d.read(ctx, id, 0)
+}
+
+// ReadAt reads the most recent state at or below the version and the subsequent events up to the version
+func (d *db) ReadAt(ctx context.Context, id string, version uint64) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	return d.read(ctx, id, version)
+}
+
+// ReadVersion reads the current version for the specified id
+func (d *db) ReadVersion(ctx context.Context, id string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var v uint64
+	err := d.pool.QueryRow(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(version), 0) FROM %s WHERE stream_id = $1`, d.tables.events), id).Scan(&v)
+	if err != nil {
+		return 0, err
+	}
+
+	if v == 0 {
+		return 0, salsa.ErrNotFound
+	}
+
+	return v, nil
+}
+
+// read reads the most recent state and events up to the specified version.
+// A zero version reads the latest state and events.
+func (d *db) read(ctx context.Context, id string, version uint64) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	var state salsa.EncodedState
+	var events []salsa.EncodedEvent
+
+	err := d.view(ctx, func(ptx pgx.Tx) error {
+		err := ptx.QueryRow(ctx, fmt.Sprintf(`
+			SELECT version, schema, data FROM %s
+			WHERE stream_id = $1 AND ($2::BIGINT = 0 OR version <= $2::BIGINT)
+			ORDER BY version DESC
+			LIMIT 1`, d.tables.snapshots), id, version).Scan(&state.Version, &state.Schema, &state.Data)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		events, err = d.queryEvents(ctx, ptx, `
+			WHERE stream_id = $1 AND version > $2 AND ($3::BIGINT = 0 OR version <= $3::BIGINT)
+			ORDER BY version`, id, state.Version, version)
+		return err
+	})
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	if state.Version == 0 && len(events) < 1 {
+		return salsa.EncodedState{}, nil, salsa.ErrNotFound
+	}
+
+	return state, events, nil
+}
+
+// ReadIter reads the most recent state and returns an iterator over subsequent events for the specified id.
+// The iterator holds a read only transaction open until it is closed.
+func (d *db) ReadIter(ctx context.Context, id string) (salsa.EncodedState, salsa.EventIterator, error) {
+	if err := ctx.Err(); err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	ptx, err := d.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	var state salsa.EncodedState
+	err = ptx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT version, schema, data FROM %s
+		WHERE stream_id = $1
+		ORDER BY version DESC
+		LIMIT 1`, d.tables.snapshots), id).Scan(&state.Version, &state.Schema, &state.Data)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		ptx.Rollback(ctx)
+		return salsa.EncodedState{}, nil, err
+	}
+
+	rows, err := ptx.Query(ctx, fmt.Sprintf(`
+		SELECT %s FROM %s
+		WHERE stream_id = $1 AND version > $2
+		ORDER BY version`, eventColumns, d.tables.events), id, state.Version)
+	if err != nil {
+		ptx.Rollback(ctx)
+		return salsa.EncodedState{}, nil, err
+	}
+
+	return state, &iterator{ctx: ctx, ptx: ptx, rows: rows}, nil
+}
+
+// ReadEvents reads the events for the specified id within the version range.
+// A zero to version reads all events from the specified version.
+func (d *db) ReadEvents(ctx context.Context, id string, from, to uint64) ([]salsa.EncodedEvent, error) {
+	var events []salsa.EncodedEvent
+
+	err := d.view(ctx, func(ptx pgx.Tx) error {
+		var err error
+		events, err = d.queryEvents(ctx, ptx, `
+			WHERE stream_id = $1 AND version >= $2 AND ($3::BIGINT = 0 OR version <= $3::BIGINT)
+			ORDER BY version`, id, from, to)
+		if err != nil || len(events) > 0 {
+			return err
+		}
+
+		var ok bool
+		err = ptx.QueryRow(ctx, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE stream_id = $1)`, d.tables.events), id).Scan(&ok)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return salsa.ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Write executes the specified write function within a transaction
+func (d *db) Write(ctx context.Context, id string, fn func(salsa.DBTx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, d.pool, func(ptx pgx.Tx) error {
+		return fn(&tx{
+			ctx:    ctx,
+			id:     id,
+			ptx:    ptx,
+			tables: d.tables,
+		})
+	})
+}
+
+// WriteMulti executes the specified write function for each id within a single transaction
+func (d *db) WriteMulti(ctx context.Context, ids []string, fn func(string, salsa.DBTx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, d.pool, func(ptx pgx.Tx) error {
+		for _, id := range ids {
+			// each tx reads the current global position, including positions allocated by previous ids
+			err := fn(id, &tx{
+				ctx:    ctx,
+				id:     id,
+				ptx:    ptx,
+				tables: d.tables,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ReadAll returns up to limit events from the global stream, starting at the specified position
+func (d *db) ReadAll(ctx context.Context, from uint64, limit int) ([]salsa.StreamEvent[string], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var lim *int
+	if limit > 0 {
+		lim = &limit
+	}
+
+	rows, err := d.pool.Query(ctx, fmt.Sprintf(`
+		SELECT stream_id, %s FROM %s
+		WHERE position >= $1
+		ORDER BY position
+		LIMIT $2`, eventColumns, d.tables.events), from, lim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []salsa.StreamEvent[string]
+	for rows.Next() {
+		var e salsa.StreamEvent[string]
+		if e.EncodedEvent, err = scanEvent(rows, &e.ID); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// ReadState reads the most recent state at or below the specified version.
+// A zero version reads the latest state.
+func (d *db) ReadState(ctx context.Context, id string, version uint64) (salsa.EncodedState, error) {
+	if err := ctx.Err(); err != nil {
+		return salsa.EncodedState{}, err
+	}
+
+	var state salsa.EncodedState
+	err := d.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT version, schema, data FROM %s
+		WHERE stream_id = $1 AND ($2::BIGINT = 0 OR version <= $2::BIGINT)
+		ORDER BY version DESC
+		LIMIT 1`, d.tables.snapshots), id, version).Scan(&state.Version, &state.Schema, &state.Data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return salsa.EncodedState{}, nil
+	}
+	if err != nil {
+		return salsa.EncodedState{}, err
+	}
+
+	return state, nil
+}
+
+// WriteState writes the specified state, replacing any existing state with the same version
+func (d *db) WriteState(ctx context.Context, id string, s salsa.EncodedState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := d.pool.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (stream_id, version, schema, data)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (stream_id, version) DO UPDATE SET schema = EXCLUDED.schema, data = EXCLUDED.data`, d.tables.snapshots),
+		id, s.Version, s.Schema, s.Data)
+
+	return err
+}
+
+// PurgeStates deletes all states for the specified id
+func (d *db) PurgeStates(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := d.pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE stream_id = $1`, d.tables.snapshots), id)
+	return err
+}
+
+// PurgeAllStates deletes all states for all ids
+func (d *db) PurgeAllStates(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := d.pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s`, d.tables.snapshots))
+	return err
+}
+
+// view executes the specified func within a read only transaction to provide a consistent view
+func (d *db) view(ctx context.Context, fn func(pgx.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return pgx.BeginTxFunc(ctx, d.pool, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	}, fn)
+}
+
+func (d *db) queryEvents(ctx context.Context, ptx pgx.Tx, where string, args ...any) ([]salsa.EncodedEvent, error) {
+	rows, err := ptx.Query(ctx, fmt.Sprintf(`SELECT %s FROM %s %s`, eventColumns, d.tables.events, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []salsa.EncodedEvent
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// Event writes the specified event
+func (t *tx) Event(e salsa.EncodedEvent) error {
+	// positions are allocated under an advisory lock to ensure that events are
+	// committed to the global stream in position order
+	if !t.locked {
+		if err := t.lock(); err != nil {
+			return err
+		}
+	}
+
+	v, err := t.currentVersion()
+	if err != nil {
+		return err
+	}
+
+	if e.Version != v+1 {
+		return &salsa.ConflictError{ID: t.id, Expected: e.Version - 1, Actual: v}
+	}
+
+	var hdrs []byte
+	if len(e.Metadata.Headers) > 0 {
+		if hdrs, err = json.Marshal(e.Metadata.Headers); err != nil {
+			return err
+		}
+	}
+
+	_, err = t.ptx.Exec(t.ctx, fmt.Sprintf(`
+		INSERT INTO %s (position, stream_id, version, type, schema, data, event_id, timestamp, correlation_id, causation_id, headers)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`, t.tables.events),
+		t.position+1, t.id, e.Version, e.Type, e.Schema, e.Data, e.Metadata.EventID, e.Metadata.Timestamp,
+		e.Metadata.CorrelationID, e.Metadata.CausationID, hdrs)
+	if err != nil {
+		var perr *pgconn.PgError
+		if errors.As(err, &perr) && perr.Code == uniqueViolation {
+			return &salsa.ConflictError{ID: t.id, Expected: e.Version - 1, Actual: v}
+		}
+		return err
+	}
+
+	*t.version = e.Version
+	t.position++
+	return nil
+}
+
+// State writes the specified state
+func (t *tx) State(s salsa.EncodedState) error {
+	v, err := t.currentVersion()
+	if err != nil {
+		return err
+	}
+
+	if s.Version != v {
+		return &salsa.ConflictError{ID: t.id, Expected: s.Version, Actual: v}
+	}
+
+	// states are derived from events, so can be overwritten
+	_, err = t.ptx.Exec(t.ctx, fmt.Sprintf(`
+		INSERT INTO %s (stream_id, version, schema, data)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (stream_id, version) DO UPDATE SET schema = EXCLUDED.schema, data = EXCLUDED.data`, t.tables.snapshots),
+		t.id, s.Version, s.Schema, s.Data)
+
+	return err
+}
+
+func (t *tx) lock() error {
+	_, err := t.ptx.Exec(t.ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, t.tables.name)
+	if err != nil {
+		return err
+	}
+
+	err = t.ptx.QueryRow(t.ctx, fmt.Sprintf(`SELECT COALESCE(MAX(position), 0) FROM %s`, t.tables.events)).Scan(&t.position)
+	if err != nil {
+		return err
+	}
+
+	// the version may have changed while waiting for the lock
+	t.locked = true
+	t.version = nil
+	return nil
+}
+
+func (t *tx) currentVersion() (uint64, error) {
+	if t.version != nil {
+		return *t.version, nil
+	}
+
+	var v uint64
+	err := t.ptx.QueryRow(t.ctx, fmt.Sprintf(`SELECT COALESCE(MAX(version), 0) FROM %s WHERE stream_id = $1`, t.tables.events), t.id).Scan(&v)
+	if err != nil {
+		return 0, err
+	}
+
+	t.version = &v
+	return v, nil
+}
+
+const eventColumns = "position, version, type, schema, data, event_id, timestamp, correlation_id, causation_id, headers"
+
+// Next advances the iterator to the next event
+func (i *iterator) Next() bool {
+	if i.rows == nil || i.err != nil || !i.rows.Next() {
+		return false
+	}
+
+	i.event, i.err = scanEvent(i.rows)
+	return i.err == nil
+}
+
+// Event returns the current event
+func (i *iterator) Event() salsa.EncodedEvent {
+	return i.event
+}
+
+// Err returns the iteration error
+func (i *iterator) Err() error {
+	if i.err != nil || i.rows == nil {
+		return i.err
+	}
+
+	return i.rows.Err()
+}
+
+// Close closes the iterator, releasing the read only transaction
+func (i *iterator) Close() error {
+	if i.rows == nil {
+		return nil
+	}
+
+	i.rows.Close()
+	i.rows = nil
+
+	return i.ptx.Rollback(i.ctx)
+}
+
+func scanEvent(row pgx.Row, dest ...any) (salsa.EncodedEvent, error) {
+	var e salsa.EncodedEvent
+	var hdrs []byte
+
+	err := row.Scan(append(dest, &e.Position, &e.Version, &e.Type, &e.Schema, &e.Data, &e.Metadata.EventID,
+		&e.Metadata.Timestamp, &e.Metadata.CorrelationID, &e.Metadata.CausationID, &hdrs)...)
+	if err != nil {
+		return salsa.EncodedEvent{}, err
+	}
+
+	e.Metadata.Timestamp = e.Metadata.Timestamp.UTC()
+
+	if len(hdrs) > 0 {
+		if err = json.Unmarshal(hdrs, &e.Metadata.Headers); err != nil {
+			return salsa.EncodedEvent{}, err
+		}
+	}
+
+	return e, nil
+}
+
+func newTables(name string) tables {
+	return tables{
+		name:      name,
+		events:    pgx.Identifier{name}.Sanitize(),
+		snapshots: pgx.Identifier{name + "_snapshots"}.Sanitize(),
+	}
+}
@@ -0,0 +1,239 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/stevecallear/salsa"
+	"github.com/stevecallear/salsa/salsatest"
+	"github.com/stevecallear/salsa/store/postgres"
+)
+
+func TestMain(m *testing.M) {
+	pool = newLocalPool()
+	defer pool.Close()
+
+	for _, tn := range []string{testCreateTablesName, testNewName, testNewDBName, testNewSnapshotStore} {
+		_, err := pool.Exec(context.Background(), `DROP TABLE IF EXISTS "`+tn+`", "`+tn+`_snapshots"`)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	m.Run()
+}
+
+const (
+	testCreateTablesName = "salsa_testcreatetables"
+	testNewName          = "salsa_testnew"
+	testNewDBName        = "salsa_testnewdb"
+	testNewSnapshotStore = "salsa_testnewsnapshotstore"
+)
+
+var pool *pgxpool.Pool
+
+func TestCreateTables(t *testing.T) {
+	t.Run("should create the tables", func(t *testing.T) {
+		err := postgres.CreateTables(context.Background(), pool, testCreateTablesName)
+		assertErrorExists(t, err, false)
+
+		for _, tn := range []string{testCreateTablesName, testCreateTablesName + "_snapshots"} {
+			var ok bool
+			err = pool.QueryRow(context.Background(), `SELECT to_regclass($1) IS NOT NULL`, tn).Scan(&ok)
+			assertErrorExists(t, err, false)
+
+			if act, exp := ok, true; act != exp {
+				t.Errorf("got %v, expected %v", act, exp)
+			}
+		}
+	})
+
+	t.Run("should not return an error if the tables exist", func(t *testing.T) {
+		err := postgres.CreateTables(context.Background(), pool, testCreateTablesName)
+		assertErrorExists(t, err, false)
+	})
+}
+
+func TestNew(t *testing.T) {
+	if err := postgres.CreateTables(context.Background(), pool, testNewName); err != nil {
+		t.Fatal(err)
+	}
+
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := postgres.New(pool, testNewName, salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](5))
+
+	id := uuid.NewString()
+	t.Run("should return an error if the aggregate does not exist", func(t *testing.T) {
+		_, err := sut.Get(context.Background(), id)
+		if !errors.Is(err, salsa.ErrNotFound) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrNotFound)
+		}
+	})
+
+	t.Run("should write the aggregate", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		for i := 1; i <= 12; i++ {
+			_, err := a.Apply(&event{Amount: i * 10})
+			assertErrorExists(t, err, false)
+		}
+
+		err := sut.Save(context.Background(), id, a)
+		assertErrorExists(t, err, false)
+	})
+
+	t.Run("should return an error if a conflict occurs", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 100})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), id, a)
+
+		var cerr *salsa.ConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: id, Expected: 0, Actual: 12})
+	})
+
+	t.Run("should return an error if a new stream exists", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 100})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), id, a, salsa.WithNewStream())
+		if !errors.Is(err, salsa.ErrConflict) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrConflict)
+		}
+
+		ok, err := sut.Exists(context.Background(), id)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, ok, true)
+	})
+
+	t.Run("should read the aggregate", func(t *testing.T) {
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 780},
+			versions: salsa.Versions{
+				State:   12,
+				Initial: 12,
+				Current: 12,
+			},
+		})
+	})
+
+	t.Run("should purge snapshots", func(t *testing.T) {
+		err := sut.PurgeSnapshots(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 780},
+			versions: salsa.Versions{
+				State:   0,
+				Initial: 12,
+				Current: 12,
+			},
+		})
+	})
+}
+
+func TestNewDB(t *testing.T) {
+	if err := postgres.CreateTables(context.Background(), pool, testNewDBName); err != nil {
+		t.Fatal(err)
+	}
+
+	salsatest.RunDBSuite(t, func() salsa.DB[string] {
+		return postgres.NewDB(pool, testNewDBName)
+	})
+}
+
+func TestNewSnapshotStore(t *testing.T) {
+	if err := postgres.CreateTables(context.Background(), pool, testNewSnapshotStore); err != nil {
+		t.Fatal(err)
+	}
+
+	salsatest.RunSnapshotStoreSuite(t, func() salsa.SnapshotStore[string] {
+		return postgres.NewSnapshotStore(pool, testNewSnapshotStore)
+	})
+}
+
+func newLocalPool() *pgxpool.Pool {
+	url := os.Getenv("POSTGRES_URL")
+	if url == "" {
+		url = "postgres://postgres:postgres@db:5432/postgres?sslmode=disable"
+	}
+
+	p, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+type (
+	state struct {
+		Balance int `json:"balance"`
+	}
+
+	event struct {
+		Amount int `json:"amount"`
+	}
+
+	aggregate struct {
+		state    state
+		versions salsa.Versions
+		events   []salsa.Event[state]
+	}
+)
+
+func (e *event) Type() string {
+	return "event"
+}
+
+func (e *event) Apply(s state) (state, error) {
+	s.Balance += e.Amount
+	return s, nil
+}
+
+func assertErrorExists(t *testing.T, act error, exp bool) {
+	if act != nil && !exp {
+		t.Errorf("got %v, expected nil", act)
+	}
+	if act == nil && exp {
+		t.Error("got nil, expected an error")
+	}
+}
+
+func assertAggregateEqual(t *testing.T, act *salsa.Aggregate[state], exp aggregate) {
+	a := aggregate{
+		state:    act.State(),
+		versions: act.Versions(),
+		events:   act.Events(),
+	}
+
+	if !reflect.DeepEqual(a, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
+
+func assertDeepEqual(t *testing.T, act, exp interface{}) {
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
@@ -0,0 +1,556 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/stevecallear/salsa"
+)
+
+type (
+	db struct {
+		client redis.UniversalClient
+		keys   keys
+	}
+
+	tx struct {
+		args []any
+	}
+
+	iterator struct {
+		ctx    context.Context
+		db     *db
+		id     string
+		from   uint64
+		events []salsa.EncodedEvent
+		event  salsa.EncodedEvent
+		err    error
+		done   bool
+	}
+
+	keys struct {
+		prefix string
+	}
+)
+
+const (
+	opEvent = "E"
+	opState = "S"
+
+	argsPerOp = 10
+
+	// iterPageSize is the number of stream entries read by each iterator request
+	iterPageSize = 100
+)
+
+// writeScript atomically validates and writes the transaction operations for one or more streams.
+// The global stream is specified as the first key, followed by the stream and snapshot keys for
+// each id. Each id is specified as the id and operation count args, followed by 10 args per
+// operation. No keys are written unless all operations are valid. The script returns the
+// expected and actual versions, along with the index of the id, if a conflict occurs.
+var writeScript = redis.NewScript(`
+local all = KEYS[1]
+
+local function version(key)
+	local last = redis.call('XREVRANGE', key, '+', '-', 'COUNT', 1)
+	if #last < 1 then
+		return 0
+	end
+	return tonumber(string.match(last[1][1], '^(%d+)'))
+end
+
+local streams = {}
+local i, k = 1, 2
+while i <= #ARGV do
+	local s = {id = ARGV[i], stream = KEYS[k], snapshot = KEYS[k + 1], first = i + 2}
+	s.last = i + 1 + tonumber(ARGV[i + 1]) * 10
+
+	local v = version(s.stream)
+	for j = s.first, s.last, 10 do
+		local ov = tonumber(ARGV[j + 1])
+		if ARGV[j] == 'E' then
+			if ov ~= v + 1 then
+				return {0, ov - 1, v, #streams}
+			end
+			v = ov
+		elseif ov ~= v then
+			return {0, ov, v, #streams}
+		end
+	end
+
+	streams[#streams + 1] = s
+	i, k = s.last + 1, k + 2
+end
+
+local pos = version(all)
+for _, s in ipairs(streams) do
+	for j = s.first, s.last, 10 do
+		if ARGV[j] == 'E' then
+			pos = pos + 1
+			local fields = {
+				'type', ARGV[j + 2], 'schema', ARGV[j + 3], 'data', ARGV[j + 4],
+				'eventId', ARGV[j + 5], 'timestamp', ARGV[j + 6],
+				'correlationId', ARGV[j + 7], 'causationId', ARGV[j + 8], 'headers', ARGV[j + 9]}
+
+			redis.call('XADD', s.stream, ARGV[j + 1] .. '-0', 'position', pos, unpack(fields))
+			redis.call('XADD', all, pos .. '-0', 'id', s.id, 'version', ARGV[j + 1], unpack(fields))
+		else
+			redis.call('HSET', s.snapshot, 'version', ARGV[j + 1], 'schema', ARGV[j + 3], 'data', ARGV[j + 4])
+		end
+	end
+end
+
+return {1}
+`)
+
+// New returns a new event store backed by redis. All keys are prefixed with the specified value.
+func New[T any](c redis.UniversalClient, prefix string, optFns ...func(*salsa.Options[T])) *salsa.Store[string, T] {
+	return salsa.NewStore[string](NewDB(c, prefix), optFns...)
+}
+
+// NewDB returns a new events DB backed by redis. All keys are prefixed with the specified value.
+func NewDB(c redis.UniversalClient, prefix string) salsa.DB[string] {
+	return &db{
+		client: c,
+		keys:   keys{prefix: prefix},
+	}
+}
+
+// Read reads most recent state and events for the specified id
+func (d *db) Read(ctx context.Context, id string) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	return d.read(ctx, id, 0)
+}
+
+// ReadAt reads the state and events up to the version. Only the latest snapshot is stored,
+// so events are read from the start of the stream if the snapshot is after the version.
+func (d *db) ReadAt(ctx context.Context, id string, version uint64) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	return d.read(ctx, id, version)
+}
+
+// ReadVersion reads the current version for the specified id from the last stream entry
+func (d *db) ReadVersion(ctx context.Context, id string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	msgs, err := d.client.XRevRangeN(ctx, d.keys.stream(id), "+", "-", 1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(msgs) < 1 {
+		return 0, salsa.ErrNotFound
+	}
+
+	return parseUint(strings.TrimSuffix(msgs[0].ID, "-0"))
+}
+
+// ReadIter reads the most recent state and returns an iterator over subsequent events for the specified id.
+// Events are read in pages as the iterator advances.
+func (d *db) ReadIter(ctx context.Context, id string) (salsa.EncodedState, salsa.EventIterator, error) {
+	if err := ctx.Err(); err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	m, err := d.client.HGetAll(ctx, d.keys.snapshot(id)).Result()
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	var state salsa.EncodedState
+	if len(m) > 0 {
+		if state, err = mapToState(m); err != nil {
+			return salsa.EncodedState{}, nil, err
+		}
+	}
+
+	return state, &iterator{
+		ctx:  ctx,
+		db:   d,
+		id:   id,
+		from: state.Version + 1,
+	}, nil
+}
+
+// ReadEvents reads the events for the specified id within the version range.
+// A zero to version reads all events from the specified version.
+func (d *db) ReadEvents(ctx context.Context, id string, from, to uint64) ([]salsa.EncodedEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	events, err := d.readEvents(ctx, id, from, to)
+	if err != nil || len(events) > 0 {
+		return events, err
+	}
+
+	n, err := d.client.Exists(ctx, d.keys.stream(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 1 {
+		return nil, salsa.ErrNotFound
+	}
+
+	return nil, nil
+}
+
+// Write executes the specified write function within a transaction
+func (d *db) Write(ctx context.Context, id string, fn func(salsa.DBTx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	t := new(tx)
+	if err := fn(t); err != nil {
+		return err
+	}
+
+	return d.write(ctx, []string{id}, []*tx{t})
+}
+
+// WriteMulti executes the specified write function for each id within a single transaction
+func (d *db) WriteMulti(ctx context.Context, ids []string, fn func(string, salsa.DBTx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ts := make([]*tx, len(ids))
+	for i, id := range ids {
+		ts[i] = new(tx)
+		if err := fn(id, ts[i]); err != nil {
+			return err
+		}
+	}
+
+	return d.write(ctx, ids, ts)
+}
+
+// ReadAll returns up to limit events from the global stream, starting at the specified position
+func (d *db) ReadAll(ctx context.Context, from uint64, limit int) ([]salsa.StreamEvent[string], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	start := "-"
+	if from > 0 {
+		start = entryID(from)
+	}
+
+	var msgs []redis.XMessage
+	var err error
+	if limit > 0 {
+		msgs, err = d.client.XRangeN(ctx, d.keys.all(), start, "+", int64(limit)).Result()
+	} else {
+		msgs, err = d.client.XRange(ctx, d.keys.all(), start, "+").Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]salsa.StreamEvent[string], len(msgs))
+	for i, msg := range msgs {
+		e, err := messageToEvent(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		e.Position = e.Version
+		if e.Version, err = parseUint(msg.Values["version"]); err != nil {
+			return nil, err
+		}
+
+		events[i] = salsa.StreamEvent[string]{
+			ID:           fmt.Sprint(msg.Values["id"]),
+			EncodedEvent: e,
+		}
+	}
+
+	return events, nil
+}
+
+// PurgeStates deletes all states for the specified id
+func (d *db) PurgeStates(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return d.client.Del(ctx, d.keys.snapshot(id)).Err()
+}
+
+// PurgeAllStates deletes all states for all ids
+func (d *db) PurgeAllStates(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	iter := d.client.Scan(ctx, 0, d.keys.snapshot("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		if err := d.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
+// read reads the most recent state and events up to the specified version.
+// A zero version reads the latest state and events.
+func (d *db) read(ctx context.Context, id string, version uint64) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	m, err := d.client.HGetAll(ctx, d.keys.snapshot(id)).Result()
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	var state salsa.EncodedState
+	if len(m) > 0 {
+		if state, err = mapToState(m); err != nil {
+			return salsa.EncodedState{}, nil, err
+		}
+	}
+
+	if version > 0 && state.Version > version {
+		state = salsa.EncodedState{}
+	}
+
+	events, err := d.readEvents(ctx, id, state.Version+1, version)
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	if state.Version == 0 && len(events) < 1 {
+		return salsa.EncodedState{}, nil, salsa.ErrNotFound
+	}
+
+	return state, events, nil
+}
+
+func (d *db) write(ctx context.Context, ids []string, ts []*tx) error {
+	ks := []string{d.keys.all()}
+	var args []any
+	var wids []string
+	for i, t := range ts {
+		if len(t.args) < 1 {
+			continue
+		}
+
+		ks = append(ks, d.keys.stream(ids[i]), d.keys.snapshot(ids[i]))
+		args = append(append(args, ids[i], len(t.args)/argsPerOp), t.args...)
+		wids = append(wids, ids[i])
+	}
+
+	if len(wids) < 1 {
+		return nil
+	}
+
+	res, err := writeScript.Run(ctx, d.client, ks, args...).Int64Slice()
+	if err != nil {
+		return err
+	}
+
+	if res[0] == 0 {
+		return &salsa.ConflictError{ID: wids[res[3]], Expected: uint64(res[1]), Actual: uint64(res[2])}
+	}
+
+	return nil
+}
+
+func (d *db) readEvents(ctx context.Context, id string, from, to uint64) ([]salsa.EncodedEvent, error) {
+	end := "+"
+	if to > 0 {
+		end = entryID(to)
+	}
+
+	msgs, err := d.client.XRange(ctx, d.keys.stream(id), entryID(from), end).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return messagesToEvents(msgs)
+}
+
+// Next advances the iterator to the next event, reading the next page of events if required
+func (i *iterator) Next() bool {
+	if len(i.events) < 1 {
+		if i.done || i.err != nil {
+			return false
+		}
+
+		if i.err = i.ctx.Err(); i.err != nil {
+			return false
+		}
+
+		var msgs []redis.XMessage
+		msgs, i.err = i.db.client.XRangeN(i.ctx, i.db.keys.stream(i.id), entryID(i.from), "+", iterPageSize).Result()
+		if i.err != nil {
+			return false
+		}
+
+		if i.events, i.err = messagesToEvents(msgs); i.err != nil {
+			return false
+		}
+
+		i.done = len(i.events) < iterPageSize
+		if len(i.events) < 1 {
+			return false
+		}
+
+		i.from = i.events[len(i.events)-1].Version + 1
+	}
+
+	i.event, i.events = i.events[0], i.events[1:]
+	return true
+}
+
+// Event returns the current event
+func (i *iterator) Event() salsa.EncodedEvent {
+	return i.event
+}
+
+// Err returns the iteration error
+func (i *iterator) Err() error {
+	return i.err
+}
+
+// Close closes the iterator
+func (i *iterator) Close() error {
+	i.events = nil
+	i.done = true
+	return nil
+}
+
+// messagesToEvents converts the aggregate stream messages to events
+func messagesToEvents(msgs []redis.XMessage) ([]salsa.EncodedEvent, error) {
+	var events []salsa.EncodedEvent
+	for _, msg := range msgs {
+		e, err := messageToEvent(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Position, err = parseUint(msg.Values["position"]); err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// Event writes the specified event
+func (t *tx) Event(e salsa.EncodedEvent) error {
+	var hdrs string
+	if len(e.Metadata.Headers) > 0 {
+		b, err := json.Marshal(e.Metadata.Headers)
+		if err != nil {
+			return err
+		}
+		hdrs = string(b)
+	}
+
+	t.args = append(t.args, opEvent, e.Version, e.Type, e.Schema, e.Data, e.Metadata.EventID,
+		e.Metadata.Timestamp.Format(time.RFC3339Nano), e.Metadata.CorrelationID, e.Metadata.CausationID, hdrs)
+
+	return nil
+}
+
+// State writes the specified state
+func (t *tx) State(s salsa.EncodedState) error {
+	t.args = append(t.args, opState, s.Version, "", s.Schema, s.Data, "", "", "", "", "")
+	return nil
+}
+
+// messageToEvent converts the stream message to an event, with the version parsed from the message id
+func messageToEvent(msg redis.XMessage) (salsa.EncodedEvent, error) {
+	var e salsa.EncodedEvent
+	var err error
+
+	if e.Version, err = parseUint(strings.TrimSuffix(msg.ID, "-0")); err != nil {
+		return e, err
+	}
+
+	e.Type = fmt.Sprint(msg.Values["type"])
+	e.Data = []byte(fmt.Sprint(msg.Values["data"]))
+	e.Metadata.EventID = fmt.Sprint(msg.Values["eventId"])
+	e.Metadata.CorrelationID = fmt.Sprint(msg.Values["correlationId"])
+	e.Metadata.CausationID = fmt.Sprint(msg.Values["causationId"])
+
+	sv, err := parseUint(msg.Values["schema"])
+	if err != nil {
+		return e, err
+	}
+	e.Schema = uint(sv)
+
+	if e.Metadata.Timestamp, err = time.Parse(time.RFC3339Nano, fmt.Sprint(msg.Values["timestamp"])); err != nil {
+		return e, err
+	}
+
+	if h := fmt.Sprint(msg.Values["headers"]); h != "" {
+		if err = json.Unmarshal([]byte(h), &e.Metadata.Headers); err != nil {
+			return e, err
+		}
+	}
+
+	return e, nil
+}
+
+func mapToState(m map[string]string) (salsa.EncodedState, error) {
+	v, err := parseUint(m["version"])
+	if err != nil {
+		return salsa.EncodedState{}, err
+	}
+
+	sv, err := parseUint(m["schema"])
+	if err != nil {
+		return salsa.EncodedState{}, err
+	}
+
+	return salsa.EncodedState{
+		Version: v,
+		Schema:  uint(sv),
+		Data:    []byte(m["data"]),
+	}, nil
+}
+
+func parseUint(v any) (uint64, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, errors.New("invalid numeric value")
+	}
+
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func entryID(v uint64) string {
+	return strconv.FormatUint(v, 10) + "-0"
+}
+
+// keys are prefixed with a hash tag to ensure that they are allocated to the same cluster slot
+func (k keys) stream(id string) string {
+	return "{" + k.prefix + "}:stream:" + id
+}
+
+func (k keys) snapshot(id string) string {
+	return "{" + k.prefix + "}:snapshot:" + id
+}
+
+func (k keys) snapshots(id string) string {
+	return "{" + k.prefix + "}:snapshots:" + id
+}
+
+func (k keys) all() string {
+	return "{" + k.prefix + "}:$all"
+}
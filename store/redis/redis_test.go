@@ -0,0 +1,270 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/stevecallear/salsa"
+	"github.com/stevecallear/salsa/salsatest"
+	"github.com/stevecallear/salsa/store/redis"
+)
+
+func TestNew(t *testing.T) {
+	c, cleanup := newClient(t)
+	defer cleanup()
+
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := redis.New(c, "testnew", salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](5))
+
+	id := uuid.NewString()
+	t.Run("should return an error if the aggregate does not exist", func(t *testing.T) {
+		_, err := sut.Get(context.Background(), id)
+		if !errors.Is(err, salsa.ErrNotFound) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrNotFound)
+		}
+	})
+
+	t.Run("should write the aggregate", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		for i := 1; i <= 12; i++ {
+			_, err := a.Apply(&event{Amount: i * 10})
+			assertErrorExists(t, err, false)
+		}
+
+		err := sut.Save(context.Background(), id, a)
+		assertErrorExists(t, err, false)
+	})
+
+	t.Run("should return an error if a conflict occurs", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 100})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), id, a)
+
+		var cerr *salsa.ConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: id, Expected: 0, Actual: 12})
+	})
+
+	t.Run("should return an error if a new stream exists", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 100})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), id, a, salsa.WithNewStream())
+		if !errors.Is(err, salsa.ErrConflict) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrConflict)
+		}
+
+		ok, err := sut.Exists(context.Background(), id)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, ok, true)
+	})
+
+	t.Run("should read the aggregate (state)", func(t *testing.T) {
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 780},
+			versions: salsa.Versions{
+				State:   12,
+				Initial: 12,
+				Current: 12,
+			},
+		})
+	})
+
+	t.Run("should write additional events", func(t *testing.T) {
+		a, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		for i := 1; i <= 2; i++ {
+			_, err := a.Apply(&event{Amount: i * 10})
+			assertErrorExists(t, err, false)
+		}
+
+		ctx := salsa.ContextWithCorrelationID(context.Background(), "correlationid")
+		ctx = salsa.ContextWithCausationID(ctx, "causationid")
+		ctx = salsa.ContextWithHeaders(ctx, map[string]string{"key": "value"})
+
+		err = sut.Save(ctx, id, a)
+		assertErrorExists(t, err, false)
+	})
+
+	t.Run("should read the aggregate (state and events)", func(t *testing.T) {
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 810},
+			versions: salsa.Versions{
+				State:   12,
+				Initial: 14,
+				Current: 14,
+			},
+		})
+
+		ms := act.Metadata()
+		if act, exp := len(ms), 2; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for _, m := range ms {
+			if m.EventID == "" || m.Timestamp.IsZero() {
+				t.Errorf("got %v, expected event id and timestamp", m)
+			}
+
+			assertDeepEqual(t, []any{m.CorrelationID, m.CausationID, m.Headers},
+				[]any{"correlationid", "causationid", map[string]string{"key": "value"}})
+		}
+	})
+
+	t.Run("should read the global stream", func(t *testing.T) {
+		act, err := sut.ReadAll(context.Background(), 0, 0)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 14; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for i, e := range act {
+			assertDeepEqual(t, []any{e.ID, e.Version, e.Position}, []any{id, uint64(i + 1), uint64(i + 1)})
+		}
+
+		act, err = sut.ReadAll(context.Background(), 13, 1)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 1; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		assertDeepEqual(t, act[0].Event, &event{Amount: 10})
+		assertDeepEqual(t, act[0].Position, uint64(13))
+	})
+
+	t.Run("should purge snapshots", func(t *testing.T) {
+		err := sut.PurgeSnapshots(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 810},
+			versions: salsa.Versions{
+				State:   0,
+				Initial: 14,
+				Current: 14,
+			},
+		})
+
+		err = sut.PurgeAllSnapshots(context.Background())
+		assertErrorExists(t, err, false)
+	})
+}
+
+func TestNewDB(t *testing.T) {
+	c, cleanup := newClient(t)
+	defer cleanup()
+
+	salsatest.RunDBSuite(t, func() salsa.DB[string] {
+		return redis.NewDB(c, "testnewdb")
+	})
+}
+
+func TestNewSnapshotStore(t *testing.T) {
+	c, cleanup := newClient(t)
+	defer cleanup()
+
+	salsatest.RunSnapshotStoreSuite(t, func() salsa.SnapshotStore[string] {
+		return redis.NewSnapshotStore(c, "testnewsnapshotstore")
+	})
+}
+
+// newClient returns a client for the redis server specified by the REDIS_ADDR
+// environment variable, or an in-process miniredis server if it is not set
+func newClient(t *testing.T) (goredis.UniversalClient, func()) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		s := miniredis.RunT(t)
+		addr = s.Addr()
+	}
+
+	c := goredis.NewClient(&goredis.Options{Addr: addr})
+	if err := c.FlushDB(context.Background()).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return c, func() {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type (
+	state struct {
+		Balance int `json:"balance"`
+	}
+
+	event struct {
+		Amount int `json:"amount"`
+	}
+
+	aggregate struct {
+		state    state
+		versions salsa.Versions
+		events   []salsa.Event[state]
+	}
+)
+
+func (e *event) Type() string {
+	return "event"
+}
+
+func (e *event) Apply(s state) (state, error) {
+	s.Balance += e.Amount
+	return s, nil
+}
+
+func assertErrorExists(t *testing.T, act error, exp bool) {
+	if act != nil && !exp {
+		t.Errorf("got %v, expected nil", act)
+	}
+	if act == nil && exp {
+		t.Error("got nil, expected an error")
+	}
+}
+
+func assertAggregateEqual(t *testing.T, act *salsa.Aggregate[state], exp aggregate) {
+	a := aggregate{
+		state:    act.State(),
+		versions: act.Versions(),
+		events:   act.Events(),
+	}
+
+	if !reflect.DeepEqual(a, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
+
+func assertDeepEqual(t *testing.T, act, exp interface{}) {
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/stevecallear/salsa/store/sqlite
+
+go 1.18
+
+require (
+	github.com/glebarez/go-sqlite v1.20.3
+	github.com/google/uuid v1.5.0
+	github.com/stevecallear/salsa v0.2.1
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/sys v0.15.0 // indirect
+	modernc.org/libc v1.22.2 // indirect
+	modernc.org/mathutil v1.5.0 // indirect
+	modernc.org/memory v1.5.0 // indirect
+	modernc.org/sqlite v1.20.3 // indirect
+)
+
+replace github.com/stevecallear/salsa => ../..
@@ -0,0 +1,537 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/glebarez/go-sqlite" // registers the pure-Go sqlite driver
+
+	"github.com/stevecallear/salsa"
+)
+
+type (
+	db struct {
+		sdb *sql.DB
+	}
+
+	tx struct {
+		ctx     context.Context
+		id      string
+		stx     *sql.Tx
+		version *uint64
+	}
+
+	iterator struct {
+		stx   *sql.Tx
+		rows  *sql.Rows
+		event salsa.EncodedEvent
+		err   error
+	}
+
+	scanner interface {
+		Scan(dest ...any) error
+	}
+
+	// coder represents a driver error with an sqlite result code
+	coder interface {
+		Code() int
+	}
+)
+
+// sqliteConstraint is the primary result code for constraint violations
+const sqliteConstraint = 19
+
+// Open opens the sqlite database at the specified path. Write transactions acquire
+// the database lock immediately, with a busy timeout to allow concurrent writers to
+// wait for the lock rather than fail.
+func Open(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_txlock=immediate&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	return sql.Open("sqlite", dsn)
+}
+
+// CreateTables creates the required sqlite tables for the event store
+func CreateTables(ctx context.Context, sdb *sql.DB) error {
+	_, err := sdb.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS events (
+			position INTEGER PRIMARY KEY,
+			stream_id TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			schema INTEGER NOT NULL DEFAULT 0,
+			data BLOB NOT NULL,
+			event_id TEXT NOT NULL DEFAULT '',
+			timestamp TEXT NOT NULL,
+			correlation_id TEXT NOT NULL DEFAULT '',
+			causation_id TEXT NOT NULL DEFAULT '',
+			headers TEXT,
+			UNIQUE (stream_id, version)
+		);
+		CREATE TABLE IF NOT EXISTS snapshots (
+			stream_id TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			schema INTEGER NOT NULL DEFAULT 0,
+			data BLOB NOT NULL,
+			PRIMARY KEY (stream_id, version)
+		);`)
+
+	return err
+}
+
+// New returns a new event store backed by sqlite
+func New[T any](sdb *sql.DB, optFns ...func(*salsa.Options[T])) *salsa.Store[string, T] {
+	return salsa.NewStore[string](NewDB(sdb), optFns...)
+}
+
+// NewDB returns a new events DB backed by sqlite
+func NewDB(sdb *sql.DB) salsa.DB[string] {
+	return &db{sdb: sdb}
+}
+
+// NewSnapshotStore returns a new snapshot store backed by sqlite
+func NewSnapshotStore(sdb *sql.DB) salsa.SnapshotStore[string] {
+	return &db{sdb: sdb}
+}
+
+// Read reads most recent state and events for the specified id
+func (d *db) Read(ctx context.Context, id string) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	return d.read(ctx, id, 0)
+}
+
+// ReadAt reads the most recent state at or below the version and the subsequent events up to the version
+func (d *db) ReadAt(ctx context.Context, id string, version uint64) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	return d.read(ctx, id, version)
+}
+
+// ReadVersion reads the current version for the specified id
+func (d *db) ReadVersion(ctx context.Context, id string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var v uint64
+	err := d.sdb.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM events WHERE stream_id = ?`, id).Scan(&v)
+	if err != nil {
+		return 0, err
+	}
+
+	if v == 0 {
+		return 0, salsa.ErrNotFound
+	}
+
+	return v, nil
+}
+
+// read reads the most recent state and events up to the specified version.
+// A zero version reads the latest state and events.
+func (d *db) read(ctx context.Context, id string, version uint64) (salsa.EncodedState, []salsa.EncodedEvent, error) {
+	var state salsa.EncodedState
+	var events []salsa.EncodedEvent
+
+	err := d.view(ctx, func(stx *sql.Tx) error {
+		err := stx.QueryRowContext(ctx, `
+			SELECT version, schema, data FROM snapshots
+			WHERE stream_id = ? AND (? = 0 OR version <= ?)
+			ORDER BY version DESC
+			LIMIT 1`, id, version, version).Scan(&state.Version, &state.Schema, &state.Data)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		events, err = queryEvents(ctx, stx, `
+			WHERE stream_id = ? AND version > ? AND (? = 0 OR version <= ?)
+			ORDER BY version`, id, state.Version, version, version)
+		return err
+	})
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	if state.Version == 0 && len(events) < 1 {
+		return salsa.EncodedState{}, nil, salsa.ErrNotFound
+	}
+
+	return state, events, nil
+}
+
+// ReadIter reads the most recent state and returns an iterator over subsequent events for the specified id.
+// The iterator holds a read only transaction open until it is closed.
+func (d *db) ReadIter(ctx context.Context, id string) (salsa.EncodedState, salsa.EventIterator, error) {
+	if err := ctx.Err(); err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	stx, err := d.sdb.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return salsa.EncodedState{}, nil, err
+	}
+
+	var state salsa.EncodedState
+	err = stx.QueryRowContext(ctx, `
+		SELECT version, schema, data FROM snapshots
+		WHERE stream_id = ?
+		ORDER BY version DESC
+		LIMIT 1`, id).Scan(&state.Version, &state.Schema, &state.Data)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		stx.Rollback()
+		return salsa.EncodedState{}, nil, err
+	}
+
+	rows, err := stx.QueryContext(ctx, `
+		SELECT `+eventColumns+` FROM events
+		WHERE stream_id = ? AND version > ?
+		ORDER BY version`, id, state.Version)
+	if err != nil {
+		stx.Rollback()
+		return salsa.EncodedState{}, nil, err
+	}
+
+	return state, &iterator{stx: stx, rows: rows}, nil
+}
+
+// ReadEvents reads the events for the specified id within the version range.
+// A zero to version reads all events from the specified version.
+func (d *db) ReadEvents(ctx context.Context, id string, from, to uint64) ([]salsa.EncodedEvent, error) {
+	var events []salsa.EncodedEvent
+
+	err := d.view(ctx, func(stx *sql.Tx) error {
+		var err error
+		events, err = queryEvents(ctx, stx, `
+			WHERE stream_id = ? AND version >= ? AND (? = 0 OR version <= ?)
+			ORDER BY version`, id, from, to, to)
+		if err != nil || len(events) > 0 {
+			return err
+		}
+
+		var ok bool
+		err = stx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM events WHERE stream_id = ?)`, id).Scan(&ok)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return salsa.ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Write executes the specified write function within a transaction
+func (d *db) Write(ctx context.Context, id string, fn func(salsa.DBTx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stx, err := d.sdb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer stx.Rollback()
+
+	if err = fn(&tx{ctx: ctx, id: id, stx: stx}); err != nil {
+		return err
+	}
+
+	return stx.Commit()
+}
+
+// WriteMulti executes the specified write function for each id within a single transaction
+func (d *db) WriteMulti(ctx context.Context, ids []string, fn func(string, salsa.DBTx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stx, err := d.sdb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer stx.Rollback()
+
+	for _, id := range ids {
+		if err = fn(id, &tx{ctx: ctx, id: id, stx: stx}); err != nil {
+			return err
+		}
+	}
+
+	return stx.Commit()
+}
+
+// ReadAll returns up to limit events from the global stream, starting at the specified position
+func (d *db) ReadAll(ctx context.Context, from uint64, limit int) ([]salsa.StreamEvent[string], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// a negative limit returns all rows
+	if limit < 1 {
+		limit = -1
+	}
+
+	rows, err := d.sdb.QueryContext(ctx, `
+		SELECT stream_id, `+eventColumns+` FROM events
+		WHERE position >= ?
+		ORDER BY position
+		LIMIT ?`, from, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []salsa.StreamEvent[string]
+	for rows.Next() {
+		var e salsa.StreamEvent[string]
+		if e.EncodedEvent, err = scanEvent(rows, &e.ID); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// ReadState reads the most recent state at or below the specified version.
+// A zero version reads the latest state.
+func (d *db) ReadState(ctx context.Context, id string, version uint64) (salsa.EncodedState, error) {
+	if err := ctx.Err(); err != nil {
+		return salsa.EncodedState{}, err
+	}
+
+	var state salsa.EncodedState
+	err := d.sdb.QueryRowContext(ctx, `
+		SELECT version, schema, data FROM snapshots
+		WHERE stream_id = ? AND (? = 0 OR version <= ?)
+		ORDER BY version DESC
+		LIMIT 1`, id, version, version).Scan(&state.Version, &state.Schema, &state.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return salsa.EncodedState{}, nil
+	}
+	if err != nil {
+		return salsa.EncodedState{}, err
+	}
+
+	return state, nil
+}
+
+// WriteState writes the specified state, replacing any existing state with the same version
+func (d *db) WriteState(ctx context.Context, id string, s salsa.EncodedState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := d.sdb.ExecContext(ctx, `
+		INSERT INTO snapshots (stream_id, version, schema, data)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (stream_id, version) DO UPDATE SET schema = excluded.schema, data = excluded.data`,
+		id, s.Version, s.Schema, s.Data)
+
+	return err
+}
+
+// PurgeStates deletes all states for the specified id
+func (d *db) PurgeStates(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := d.sdb.ExecContext(ctx, `DELETE FROM snapshots WHERE stream_id = ?`, id)
+	return err
+}
+
+// PurgeAllStates deletes all states for all ids
+func (d *db) PurgeAllStates(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := d.sdb.ExecContext(ctx, `DELETE FROM snapshots`)
+	return err
+}
+
+// view executes the specified func within a read only transaction to provide a consistent view
+func (d *db) view(ctx context.Context, fn func(*sql.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stx, err := d.sdb.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer stx.Rollback()
+
+	return fn(stx)
+}
+
+// Event writes the specified event
+func (t *tx) Event(e salsa.EncodedEvent) error {
+	v, err := t.currentVersion()
+	if err != nil {
+		return err
+	}
+
+	if e.Version != v+1 {
+		return &salsa.ConflictError{ID: t.id, Expected: e.Version - 1, Actual: v}
+	}
+
+	var hdrs sql.NullString
+	if len(e.Metadata.Headers) > 0 {
+		b, err := json.Marshal(e.Metadata.Headers)
+		if err != nil {
+			return err
+		}
+		hdrs = sql.NullString{String: string(b), Valid: true}
+	}
+
+	// positions are allocated by sqlite as the table rowid
+	_, err = t.stx.ExecContext(t.ctx, `
+		INSERT INTO events (stream_id, version, type, schema, data, event_id, timestamp, correlation_id, causation_id, headers)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.id, e.Version, e.Type, e.Schema, e.Data, e.Metadata.EventID, e.Metadata.Timestamp.Format(time.RFC3339Nano),
+		e.Metadata.CorrelationID, e.Metadata.CausationID, hdrs)
+	if err != nil {
+		if isConflict(err) {
+			return &salsa.ConflictError{ID: t.id, Expected: e.Version - 1, Actual: v}
+		}
+		return err
+	}
+
+	*t.version = e.Version
+	return nil
+}
+
+// State writes the specified state
+func (t *tx) State(s salsa.EncodedState) error {
+	v, err := t.currentVersion()
+	if err != nil {
+		return err
+	}
+
+	if s.Version != v {
+		return &salsa.ConflictError{ID: t.id, Expected: s.Version, Actual: v}
+	}
+
+	// states are derived from events, so can be overwritten
+	_, err = t.stx.ExecContext(t.ctx, `
+		INSERT INTO snapshots (stream_id, version, schema, data)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (stream_id, version) DO UPDATE SET schema = excluded.schema, data = excluded.data`,
+		t.id, s.Version, s.Schema, s.Data)
+
+	return err
+}
+
+func (t *tx) currentVersion() (uint64, error) {
+	if t.version != nil {
+		return *t.version, nil
+	}
+
+	var v uint64
+	err := t.stx.QueryRowContext(t.ctx, `SELECT COALESCE(MAX(version), 0) FROM events WHERE stream_id = ?`, t.id).Scan(&v)
+	if err != nil {
+		return 0, err
+	}
+
+	t.version = &v
+	return v, nil
+}
+
+// isConflict returns true if the error is a constraint violation.
+// Extended result codes are masked to the primary code.
+func isConflict(err error) bool {
+	var cerr coder
+	if !errors.As(err, &cerr) {
+		return false
+	}
+
+	return cerr.Code()&0xff == sqliteConstraint
+}
+
+// Next advances the iterator to the next event
+func (i *iterator) Next() bool {
+	if i.rows == nil || i.err != nil || !i.rows.Next() {
+		return false
+	}
+
+	i.event, i.err = scanEvent(i.rows)
+	return i.err == nil
+}
+
+// Event returns the current event
+func (i *iterator) Event() salsa.EncodedEvent {
+	return i.event
+}
+
+// Err returns the iteration error
+func (i *iterator) Err() error {
+	if i.err != nil || i.rows == nil {
+		return i.err
+	}
+
+	return i.rows.Err()
+}
+
+// Close closes the iterator, releasing the read only transaction
+func (i *iterator) Close() error {
+	if i.rows == nil {
+		return nil
+	}
+
+	i.rows.Close()
+	i.rows = nil
+
+	return i.stx.Rollback()
+}
+
+const eventColumns = "position, version, type, schema, data, event_id, timestamp, correlation_id, causation_id, headers"
+
+func queryEvents(ctx context.Context, stx *sql.Tx, where string, args ...any) ([]salsa.EncodedEvent, error) {
+	rows, err := stx.QueryContext(ctx, `SELECT `+eventColumns+` FROM events `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []salsa.EncodedEvent
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func scanEvent(row scanner, dest ...any) (salsa.EncodedEvent, error) {
+	var e salsa.EncodedEvent
+	var ts string
+	var hdrs sql.NullString
+
+	err := row.Scan(append(dest, &e.Position, &e.Version, &e.Type, &e.Schema, &e.Data, &e.Metadata.EventID,
+		&ts, &e.Metadata.CorrelationID, &e.Metadata.CausationID, &hdrs)...)
+	if err != nil {
+		return salsa.EncodedEvent{}, err
+	}
+
+	if e.Metadata.Timestamp, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+		return salsa.EncodedEvent{}, err
+	}
+
+	if hdrs.Valid {
+		if err = json.Unmarshal([]byte(hdrs.String), &e.Metadata.Headers); err != nil {
+			return salsa.EncodedEvent{}, err
+		}
+	}
+
+	return e, nil
+}
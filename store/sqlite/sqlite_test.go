@@ -0,0 +1,323 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stevecallear/salsa"
+	"github.com/stevecallear/salsa/salsatest"
+
+	"github.com/stevecallear/salsa/store/sqlite"
+)
+
+func TestCreateTables(t *testing.T) {
+	db, cleanup := openDB(t, "sqlite_create_test.db")
+	defer cleanup()
+
+	t.Run("should create the tables", func(t *testing.T) {
+		for _, tn := range []string{"events", "snapshots"} {
+			var ok bool
+			err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, tn).Scan(&ok)
+			assertErrorExists(t, err, false)
+
+			if act, exp := ok, true; act != exp {
+				t.Errorf("got %v, expected %v", act, exp)
+			}
+		}
+	})
+
+	t.Run("should not return an error if the tables exist", func(t *testing.T) {
+		err := sqlite.CreateTables(context.Background(), db)
+		assertErrorExists(t, err, false)
+	})
+}
+
+func TestNew(t *testing.T) {
+	db, cleanup := openDB(t, "sqlite_test.db")
+	defer cleanup()
+
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := sqlite.New(db, salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](5))
+
+	id := uuid.NewString()
+	t.Run("should return an error if the aggregate does not exist", func(t *testing.T) {
+		_, err := sut.Get(context.Background(), id)
+		if !errors.Is(err, salsa.ErrNotFound) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrNotFound)
+		}
+	})
+
+	t.Run("should write the aggregate", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		for i := 1; i <= 12; i++ {
+			_, err := a.Apply(&event{Amount: i * 10})
+			assertErrorExists(t, err, false)
+		}
+
+		err := sut.Save(context.Background(), id, a)
+		assertErrorExists(t, err, false)
+	})
+
+	t.Run("should return an error if a conflict occurs", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 100})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), id, a)
+
+		var cerr *salsa.ConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: id, Expected: 0, Actual: 12})
+	})
+
+	t.Run("should return an error if a new stream exists", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 100})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), id, a, salsa.WithNewStream())
+		if !errors.Is(err, salsa.ErrConflict) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrConflict)
+		}
+
+		ok, err := sut.Exists(context.Background(), id)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, ok, true)
+	})
+
+	t.Run("should return a conflict error if a concurrent write violates the stream constraint", func(t *testing.T) {
+		cid := uuid.NewString()
+
+		// the trigger simulates a concurrent writer inserting the same version
+		_, err := db.Exec(`
+			CREATE TRIGGER concurrent_write BEFORE INSERT ON events WHEN NEW.stream_id = '` + cid + `'
+			BEGIN
+				INSERT INTO events (stream_id, version, type, data, timestamp)
+				VALUES (NEW.stream_id, NEW.version, NEW.type, NEW.data, NEW.timestamp);
+			END`)
+		assertErrorExists(t, err, false)
+
+		defer func() {
+			_, err := db.Exec(`DROP TRIGGER concurrent_write`)
+			assertErrorExists(t, err, false)
+		}()
+
+		a := new(salsa.Aggregate[state])
+		_, err = a.Apply(&event{Amount: 100})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), cid, a)
+
+		var cerr *salsa.ConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: cid, Expected: 0, Actual: 0})
+	})
+
+	t.Run("should read the aggregate (state)", func(t *testing.T) {
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 780},
+			versions: salsa.Versions{
+				State:   12,
+				Initial: 12,
+				Current: 12,
+			},
+		})
+	})
+
+	t.Run("should write additional events", func(t *testing.T) {
+		a, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		for i := 1; i <= 2; i++ {
+			_, err := a.Apply(&event{Amount: i * 10})
+			assertErrorExists(t, err, false)
+		}
+
+		ctx := salsa.ContextWithCorrelationID(context.Background(), "correlationid")
+		ctx = salsa.ContextWithCausationID(ctx, "causationid")
+		ctx = salsa.ContextWithHeaders(ctx, map[string]string{"key": "value"})
+
+		err = sut.Save(ctx, id, a)
+		assertErrorExists(t, err, false)
+	})
+
+	t.Run("should read the aggregate (state and events)", func(t *testing.T) {
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 810},
+			versions: salsa.Versions{
+				State:   12,
+				Initial: 14,
+				Current: 14,
+			},
+		})
+
+		ms := act.Metadata()
+		if act, exp := len(ms), 2; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for _, m := range ms {
+			if m.EventID == "" || m.Timestamp.IsZero() {
+				t.Errorf("got %v, expected event id and timestamp", m)
+			}
+
+			assertDeepEqual(t, []any{m.CorrelationID, m.CausationID, m.Headers},
+				[]any{"correlationid", "causationid", map[string]string{"key": "value"}})
+		}
+	})
+
+	t.Run("should read the global stream", func(t *testing.T) {
+		act, err := sut.ReadAll(context.Background(), 0, 0)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 14; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for i, e := range act {
+			assertDeepEqual(t, []any{e.ID, e.Version, e.Position}, []any{id, uint64(i + 1), uint64(i + 1)})
+		}
+
+		act, err = sut.ReadAll(context.Background(), 13, 1)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 1; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		assertDeepEqual(t, act[0].Event, &event{Amount: 10})
+		assertDeepEqual(t, act[0].Position, uint64(13))
+	})
+
+	t.Run("should purge snapshots", func(t *testing.T) {
+		err := sut.PurgeSnapshots(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		assertAggregateEqual(t, act, aggregate{
+			state: state{Balance: 810},
+			versions: salsa.Versions{
+				State:   0,
+				Initial: 14,
+				Current: 14,
+			},
+		})
+
+		err = sut.PurgeAllSnapshots(context.Background())
+		assertErrorExists(t, err, false)
+	})
+}
+
+func TestNewDB(t *testing.T) {
+	db, cleanup := openDB(t, "sqlite_db_test.db")
+	defer cleanup()
+
+	salsatest.RunDBSuite(t, func() salsa.DB[string] {
+		return sqlite.NewDB(db)
+	})
+}
+
+func TestNewSnapshotStore(t *testing.T) {
+	db, cleanup := openDB(t, "sqlite_snapshots_test.db")
+	defer cleanup()
+
+	salsatest.RunSnapshotStoreSuite(t, func() salsa.SnapshotStore[string] {
+		return sqlite.NewSnapshotStore(db)
+	})
+}
+
+func openDB(t *testing.T, fn string) (*sql.DB, func()) {
+	db, err := sqlite.Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sqlite.CreateTables(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db, func() {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{fn, fn + "-wal", fn + "-shm"} {
+			if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+type (
+	state struct {
+		Balance int `json:"balance"`
+	}
+
+	event struct {
+		Amount int `json:"amount"`
+	}
+
+	aggregate struct {
+		state    state
+		versions salsa.Versions
+		events   []salsa.Event[state]
+	}
+)
+
+func (e *event) Type() string {
+	return "event"
+}
+
+func (e *event) Apply(s state) (state, error) {
+	s.Balance += e.Amount
+	return s, nil
+}
+
+func assertErrorExists(t *testing.T, act error, exp bool) {
+	if act != nil && !exp {
+		t.Errorf("got %v, expected nil", act)
+	}
+	if act == nil && exp {
+		t.Error("got nil, expected an error")
+	}
+}
+
+func assertAggregateEqual(t *testing.T, act *salsa.Aggregate[state], exp aggregate) {
+	a := aggregate{
+		state:    act.State(),
+		versions: act.Versions(),
+		events:   act.Events(),
+	}
+
+	if !reflect.DeepEqual(a, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
+
+func assertDeepEqual(t *testing.T, act, exp interface{}) {
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("got %v, expected %v", act, exp)
+	}
+}
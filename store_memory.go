@@ -3,29 +3,42 @@ package salsa
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
 )
 
 type (
 	memDB[T comparable] struct {
-		items map[T][]memDBItem
-		mu    sync.RWMutex
+		items  map[T][]memDBItem
+		all    []StreamEvent[T]
+		outbox []StreamEvent[T]
+		mu     sync.RWMutex
 	}
 
 	memTX struct {
+		id      any
 		version uint64
 		items   []memDBItem
 		mu      sync.Mutex
 	}
 
 	memDBItem struct {
-		itype   memDBItemType
-		etype   string
-		version uint64
-		data    []byte
+		itype    memDBItemType
+		etype    string
+		version  uint64
+		schema   uint
+		position uint64
+		data     []byte
+		metadata Metadata
+		outbox   bool
 	}
 
 	memDBItemType uint8
+
+	memSnapshotStore[T comparable] struct {
+		states map[T][]EncodedState
+		mu     sync.RWMutex
+	}
 )
 
 const (
@@ -35,17 +48,31 @@ const (
 
 // NewMemoryStore returns a new in-memory event store
 func NewMemoryStore[TI comparable, TS any](optFns ...func(*Options[TS])) *Store[TI, TS] {
-	return NewStore[TI](new(memDB[TI]), optFns...)
+	return NewStore[TI](NewMemoryDB[TI](), optFns...)
+}
+
+// NewMemoryDB returns a new in-memory events DB
+func NewMemoryDB[TI comparable]() DB[TI] {
+	return new(memDB[TI])
+}
+
+// NewMemorySnapshotStore returns a new in-memory snapshot store
+func NewMemorySnapshotStore[TI comparable]() SnapshotStore[TI] {
+	return new(memSnapshotStore[TI])
 }
 
 // Read returns the initial state and events for the specified aggregate
 func (db *memDB[T]) Read(ctx context.Context, id T) (EncodedState, []EncodedEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return EncodedState{}, nil, err
+	}
+
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	items := db.items[id]
 	if len(items) < 1 {
-		return EncodedState{}, nil, errors.New("not found")
+		return EncodedState{}, nil, ErrNotFound
 	}
 
 	var state EncodedState
@@ -57,15 +84,12 @@ loop:
 		case memDBItemTypeState:
 			state = EncodedState{
 				Version: items[i].version,
+				Schema:  items[i].schema,
 				Data:    items[i].data,
 			}
 			break loop
 		case memDBItemTypeEvent:
-			events = append(events, EncodedEvent{
-				Type:    items[i].etype,
-				Version: items[i].version,
-				Data:    items[i].data,
-			})
+			events = append(events, items[i].event())
 		default:
 			return EncodedState{}, nil, errors.New("invalid item type")
 		}
@@ -75,27 +99,289 @@ loop:
 	return state, events, nil
 }
 
+// ReadAt returns the most recent state at or below the version and the subsequent events up to the version
+func (db *memDB[T]) ReadAt(ctx context.Context, id T, version uint64) (EncodedState, []EncodedEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return EncodedState{}, nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	items := db.items[id]
+	if len(items) < 1 {
+		return EncodedState{}, nil, ErrNotFound
+	}
+
+	var state EncodedState
+	var events []EncodedEvent
+
+	for _, itm := range items {
+		if itm.version > version {
+			break
+		}
+
+		switch itm.itype {
+		case memDBItemTypeState:
+			state = EncodedState{
+				Version: itm.version,
+				Schema:  itm.schema,
+				Data:    itm.data,
+			}
+			events = nil
+		case memDBItemTypeEvent:
+			events = append(events, itm.event())
+		}
+	}
+
+	return state, events, nil
+}
+
+// ReadVersion returns the current version of the specified aggregate
+func (db *memDB[T]) ReadVersion(ctx context.Context, id T) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	items := db.items[id]
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].itype == memDBItemTypeEvent {
+			return items[i].version, nil
+		}
+	}
+
+	return 0, ErrNotFound
+}
+
+// ReadIter returns the initial state and an event iterator for the specified aggregate
+func (db *memDB[T]) ReadIter(ctx context.Context, id T) (EncodedState, EventIterator, error) {
+	state, events, err := db.Read(ctx, id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return EncodedState{}, nil, err
+	}
+
+	return state, &sliceIterator{events: events}, nil
+}
+
+// ReadEvents returns the events for the specified aggregate within the version range.
+// A zero to version returns all events from the specified version.
+func (db *memDB[T]) ReadEvents(ctx context.Context, id T, from, to uint64) ([]EncodedEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	items := db.items[id]
+	if len(items) < 1 {
+		return nil, ErrNotFound
+	}
+
+	var events []EncodedEvent
+	for _, itm := range items {
+		if itm.itype != memDBItemTypeEvent || itm.version < from || (to > 0 && itm.version > to) {
+			continue
+		}
+
+		events = append(events, itm.event())
+	}
+
+	return events, nil
+}
+
 // Write writes the specified values to the store
 func (db *memDB[T]) Write(ctx context.Context, id T, fn func(DBTx) error) error {
-	var pv uint64
-	if len(db.items[id]) > 0 {
-		pv = db.items[id][len(db.items[id])-1].version
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	tx := &memTX{version: pv}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx := db.newTX(id)
 	if err := fn(tx); err != nil {
 		return err
 	}
 
+	db.commit(id, tx)
+	return nil
+}
+
+// WriteMulti writes the specified values for all ids in a single transaction
+func (db *memDB[T]) WriteMulti(ctx context.Context, ids []T, fn func(T, DBTx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	txs := make([]*memTX, len(ids))
+	for i, id := range ids {
+		txs[i] = db.newTX(id)
+		if err := fn(id, txs[i]); err != nil {
+			return err
+		}
+	}
+
+	for i, id := range ids {
+		db.commit(id, txs[i])
+	}
+
+	return nil
+}
+
+// ReadAll returns up to limit events from the global stream, starting at the specified position
+func (db *memDB[T]) ReadAll(ctx context.Context, from uint64, limit int) ([]StreamEvent[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if from > 0 {
+		from--
+	}
+
+	if from >= uint64(len(db.all)) {
+		return nil, nil
+	}
+
+	all := db.all[from:]
+	if limit > 0 && limit < len(all) {
+		all = all[:limit]
+	}
+
+	res := make([]StreamEvent[T], len(all))
+	copy(res, all)
+
+	return res, nil
+}
+
+// PurgeStates deletes all states for the specified aggregate
+func (db *memDB[T]) PurgeStates(ctx context.Context, id T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	db.purgeStates(id)
+	return nil
+}
+
+// PurgeAllStates deletes all states for all aggregates
+func (db *memDB[T]) PurgeAllStates(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for id := range db.items {
+		db.purgeStates(id)
+	}
+
+	return nil
+}
+
+// ReadOutbox returns up to limit outbox events in position order
+func (db *memDB[T]) ReadOutbox(ctx context.Context, limit int) ([]StreamEvent[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	ob := db.outbox
+	if limit > 0 && limit < len(ob) {
+		ob = ob[:limit]
+	}
+
+	res := make([]StreamEvent[T], len(ob))
+	copy(res, ob)
+
+	return res, nil
+}
+
+// DeleteOutbox deletes the outbox events with the specified positions
+func (db *memDB[T]) DeleteOutbox(ctx context.Context, positions ...uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ps := make(map[uint64]struct{}, len(positions))
+	for _, p := range positions {
+		ps[p] = struct{}{}
+	}
+
+	res := make([]StreamEvent[T], 0, len(db.outbox))
+	for _, e := range db.outbox {
+		if _, ok := ps[e.Position]; !ok {
+			res = append(res, e)
+		}
+	}
+
+	db.outbox = res
+	return nil
+}
+
+func (db *memDB[T]) newTX(id T) *memTX {
+	var pv uint64
+	if len(db.items[id]) > 0 {
+		pv = db.items[id][len(db.items[id])-1].version
+	}
+
+	return &memTX{id: id, version: pv}
+}
+
+func (db *memDB[T]) commit(id T, tx *memTX) {
 	if db.items == nil {
 		db.items = map[T][]memDBItem{}
 	}
 
+	for i, itm := range tx.items {
+		if itm.itype != memDBItemTypeEvent {
+			continue
+		}
+
+		tx.items[i].position = uint64(len(db.all) + 1)
+		se := StreamEvent[T]{ID: id, EncodedEvent: tx.items[i].event()}
+		db.all = append(db.all, se)
+
+		if itm.outbox {
+			db.outbox = append(db.outbox, se)
+		}
+	}
+
 	db.items[id] = append(db.items[id], tx.items...)
-	return nil
+}
+
+func (db *memDB[T]) purgeStates(id T) {
+	items := db.items[id]
+	if len(items) < 1 {
+		return
+	}
+
+	res := make([]memDBItem, 0, len(items))
+	for _, itm := range items {
+		if itm.itype != memDBItemTypeState {
+			res = append(res, itm)
+		}
+	}
+
+	db.items[id] = res
 }
 
 func (tx *memTX) Event(e EncodedEvent) error {
@@ -103,38 +389,143 @@ func (tx *memTX) Event(e EncodedEvent) error {
 	defer tx.mu.Unlock()
 
 	if e.Version != tx.version+1 {
-		return errors.New("version conflict")
+		return &ConflictError{ID: tx.id, Expected: e.Version - 1, Actual: tx.version}
 	}
 
 	tx.version++
 	tx.items = append(tx.items, memDBItem{
-		itype:   memDBItemTypeEvent,
-		etype:   e.Type,
-		version: e.Version,
-		data:    e.Data,
+		itype:    memDBItemTypeEvent,
+		etype:    e.Type,
+		version:  e.Version,
+		schema:   e.Schema,
+		data:     e.Data,
+		metadata: e.Metadata,
 	})
 
 	return nil
 }
 
+func (tx *memTX) Outbox(e EncodedEvent) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	for i, itm := range tx.items {
+		if itm.itype == memDBItemTypeEvent && itm.version == e.Version {
+			tx.items[i].outbox = true
+			return nil
+		}
+	}
+
+	return errors.New("outbox event not written in transaction")
+}
+
 func (tx *memTX) State(s EncodedState) error {
 	tx.mu.Lock()
 	defer tx.mu.Unlock()
 
 	if s.Version != tx.version {
-		return errors.New("version conflict")
+		return &ConflictError{ID: tx.id, Expected: s.Version, Actual: tx.version}
 	}
 
-	tx.version++
 	tx.items = append(tx.items, memDBItem{
 		itype:   memDBItemTypeState,
 		version: s.Version,
+		schema:  s.Schema,
 		data:    s.Data,
 	})
 
 	return nil
 }
 
+// ReadState returns the most recent state at or below the specified version.
+// A zero version returns the latest state.
+func (ss *memSnapshotStore[T]) ReadState(ctx context.Context, id T, version uint64) (EncodedState, error) {
+	if err := ctx.Err(); err != nil {
+		return EncodedState{}, err
+	}
+
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	states := ss.states[id]
+	for i := len(states) - 1; i >= 0; i-- {
+		if version == 0 || states[i].Version <= version {
+			return states[i], nil
+		}
+	}
+
+	return EncodedState{}, nil
+}
+
+// WriteState writes the specified state, replacing any existing state with the same version
+func (ss *memSnapshotStore[T]) WriteState(ctx context.Context, id T, s EncodedState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.states == nil {
+		ss.states = map[T][]EncodedState{}
+	}
+
+	// states are ordered by version, as background writes can complete out of order
+	states := ss.states[id]
+	i := sort.Search(len(states), func(i int) bool {
+		return states[i].Version >= s.Version
+	})
+
+	if i < len(states) && states[i].Version == s.Version {
+		states[i] = s
+		return nil
+	}
+
+	states = append(states, EncodedState{})
+	copy(states[i+1:], states[i:])
+	states[i] = s
+	ss.states[id] = states
+
+	return nil
+}
+
+// PurgeStates deletes all states for the specified aggregate
+func (ss *memSnapshotStore[T]) PurgeStates(ctx context.Context, id T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	delete(ss.states, id)
+	return nil
+}
+
+// PurgeAllStates deletes all states for all aggregates
+func (ss *memSnapshotStore[T]) PurgeAllStates(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.states = nil
+	return nil
+}
+
+func (i memDBItem) event() EncodedEvent {
+	return EncodedEvent{
+		Type:     i.etype,
+		Version:  i.version,
+		Schema:   i.schema,
+		Position: i.position,
+		Data:     i.data,
+		Metadata: i.metadata,
+	}
+}
+
 func reverse[T any](s []T) {
 	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
 		s[i], s[j] = s[j], s[i]
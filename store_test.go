@@ -2,9 +2,12 @@ package salsa_test
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stevecallear/salsa"
+	"github.com/stevecallear/salsa/salsatest"
 )
 
 func TestStore(t *testing.T) {
@@ -18,7 +21,9 @@ func TestStore(t *testing.T) {
 
 	t.Run("should return an error if the aggregate does not exist", func(t *testing.T) {
 		_, err := sut.Get(context.Background(), id)
-		assertErrorExists(t, err, true)
+		if !errors.Is(err, salsa.ErrNotFound) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrNotFound)
+		}
 	})
 
 	t.Run("should write the aggregate", func(t *testing.T) {
@@ -38,7 +43,13 @@ func TestStore(t *testing.T) {
 		assertErrorExists(t, err, false)
 
 		err = sut.Save(context.Background(), id, a)
-		assertErrorExists(t, err, true)
+
+		var cerr *salsa.ConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: id, Expected: 0, Actual: 12})
 	})
 
 	t.Run("should read the aggregate (state)", func(t *testing.T) {
@@ -64,7 +75,11 @@ func TestStore(t *testing.T) {
 			assertErrorExists(t, err, false)
 		}
 
-		err = sut.Save(context.Background(), id, a)
+		ctx := salsa.ContextWithCorrelationID(context.Background(), "correlationid")
+		ctx = salsa.ContextWithCausationID(ctx, "causationid")
+		ctx = salsa.ContextWithHeaders(ctx, map[string]string{"key": "value"})
+
+		err = sut.Save(ctx, id, a)
 		assertErrorExists(t, err, false)
 	})
 
@@ -80,5 +95,1034 @@ func TestStore(t *testing.T) {
 				Current: 14,
 			},
 		})
+
+		ms := act.Metadata()
+		if act, exp := len(ms), 2; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for _, m := range ms {
+			if m.EventID == "" || m.Timestamp.IsZero() {
+				t.Errorf("got %v, expected event id and timestamp", m)
+			}
+
+			assertDeepEqual(t, []any{m.CorrelationID, m.CausationID, m.Headers},
+				[]any{"correlationid", "causationid", map[string]string{"key": "value"}})
+		}
+	})
+
+	t.Run("should read the global stream", func(t *testing.T) {
+		act, err := sut.ReadAll(context.Background(), 0, 0)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 14; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		for i, e := range act {
+			assertDeepEqual(t, []any{e.ID, e.Version, e.Position}, []any{id, uint64(i + 1), uint64(i + 1)})
+		}
+
+		act, err = sut.ReadAll(context.Background(), 13, 1)
+		assertErrorExists(t, err, false)
+
+		if act, exp := len(act), 1; act != exp {
+			t.Fatalf("got %d, expected %d", act, exp)
+		}
+
+		assertDeepEqual(t, act[0].Event, &event{Amount: 10})
+		assertDeepEqual(t, act[0].Position, uint64(13))
+	})
+}
+
+func TestStore_SaveCommit(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](2))
+	a := new(salsa.Aggregate[state])
+
+	tests := []struct {
+		name   string
+		events int
+		exp    salsa.Versions
+	}{
+		{
+			name:   "should commit the events",
+			events: 2,
+			exp:    salsa.Versions{State: 0, Initial: 2, Current: 2},
+		},
+		{
+			name:   "should commit the events and state",
+			events: 1,
+			exp:    salsa.Versions{State: 3, Initial: 3, Current: 3},
+		},
+		{
+			name: "should save without events",
+			exp:  salsa.Versions{State: 3, Initial: 3, Current: 3},
+		},
+		{
+			name:   "should save additional events",
+			events: 1,
+			exp:    salsa.Versions{State: 3, Initial: 4, Current: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < tt.events; i++ {
+				_, err := a.Apply(&event{Amount: 10})
+				assertErrorExists(t, err, false)
+			}
+
+			err := sut.Save(context.Background(), "id", a)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, a.Versions(), tt.exp)
+			assertDeepEqual(t, a.Events(), []salsa.Event[state](nil))
+
+			act, err := sut.Get(context.Background(), "id")
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act.Versions(), tt.exp)
+			assertDeepEqual(t, act.State(), a.State())
+		})
+	}
+}
+
+func TestStore_Get(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	tests := []struct {
+		name string
+		db   salsa.DB[string]
+	}{
+		{
+			name: "iterator db",
+			db:   salsa.NewMemoryDB[string](),
+		},
+		{
+			name: "slice db",
+			db:   struct{ salsa.DB[string] }{salsa.NewMemoryDB[string]()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := salsa.NewStore[string](tt.db, salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](5))
+
+			_, err := sut.Get(context.Background(), "id")
+			if !errors.Is(err, salsa.ErrNotFound) {
+				t.Errorf("got %v, expected %v", err, salsa.ErrNotFound)
+			}
+
+			a := new(salsa.Aggregate[state])
+			for i := 1; i <= 8; i++ {
+				_, err = a.Apply(&event{Amount: i})
+				assertErrorExists(t, err, false)
+			}
+
+			err = sut.Save(context.Background(), "id", a)
+			assertErrorExists(t, err, false)
+
+			_, err = sut.Append(context.Background(), "id", 8, &event{Amount: 9})
+			assertErrorExists(t, err, false)
+
+			act, err := sut.Get(context.Background(), "id")
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act.State(), state{Balance: 45})
+			assertDeepEqual(t, act.Versions(), salsa.Versions{State: 8, Initial: 9, Current: 9})
+
+			if act.Metadata()[0].EventID == "" {
+				t.Error("got an empty event id, expected the persisted metadata")
+			}
+		})
+	}
+}
+
+func TestStore_GetAt(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	dbs := []struct {
+		name string
+		db   salsa.DB[string]
+	}{
+		{
+			name: "version db",
+			db:   salsa.NewMemoryDB[string](),
+		},
+		{
+			name: "events db",
+			db:   struct{ salsa.DB[string] }{salsa.NewMemoryDB[string]()},
+		},
+	}
+
+	for _, d := range dbs {
+		t.Run(d.name, func(t *testing.T) {
+			sut := salsa.NewStore[string](d.db, salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](2))
+
+			a := new(salsa.Aggregate[state])
+			for i := 1; i <= 6; i++ {
+				_, err := a.Apply(&event{Amount: i})
+				assertErrorExists(t, err, false)
+
+				err = sut.Save(context.Background(), "id", a)
+				assertErrorExists(t, err, false)
+			}
+
+			tests := []struct {
+				name    string
+				version uint64
+				exp     state
+				err     error
+			}{
+				{
+					name:    "should return an error if the version is zero",
+					version: 0,
+					err:     salsa.ErrNotFound,
+				},
+				{
+					name:    "should return the aggregate at the first version",
+					version: 1,
+					exp:     state{Balance: 1},
+				},
+				{
+					name:    "should return the aggregate at a snapshot version",
+					version: 3,
+					exp:     state{Balance: 6},
+				},
+				{
+					name:    "should return the aggregate after a snapshot version",
+					version: 4,
+					exp:     state{Balance: 10},
+				},
+				{
+					name:    "should return the aggregate at the current version",
+					version: 6,
+					exp:     state{Balance: 21},
+				},
+				{
+					name:    "should return an error if the version does not exist",
+					version: 7,
+					err:     salsa.ErrNotFound,
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					act, err := sut.GetAt(context.Background(), "id", tt.version)
+					if !errors.Is(err, tt.err) {
+						t.Fatalf("got %v, expected %v", err, tt.err)
+					}
+
+					if err == nil {
+						assertDeepEqual(t, act.State(), tt.exp)
+						assertDeepEqual(t, act.Versions().Current, tt.version)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestStore_GetAsOf(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+
+	_, err := sut.Append(context.Background(), "id", salsa.ExpectNoStream, &event{Amount: 10}, &event{Amount: 20})
+	assertErrorExists(t, err, false)
+
+	time.Sleep(time.Millisecond)
+	between := time.Now()
+	time.Sleep(time.Millisecond)
+
+	_, err = sut.Append(context.Background(), "id", 2, &event{Amount: 30})
+	assertErrorExists(t, err, false)
+
+	tests := []struct {
+		name string
+		time time.Time
+		exp  salsa.Versions
+		err  error
+	}{
+		{
+			name: "should return an error if the aggregate did not exist",
+			time: before,
+			err:  salsa.ErrNotFound,
+		},
+		{
+			name: "should return the aggregate at the time",
+			time: between,
+			exp:  salsa.Versions{Initial: 2, Current: 2},
+		},
+		{
+			name: "should return the current aggregate",
+			time: time.Now(),
+			exp:  salsa.Versions{Initial: 3, Current: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			act, err := sut.GetAsOf(context.Background(), "id", tt.time)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, expected %v", err, tt.err)
+			}
+
+			if err == nil {
+				assertDeepEqual(t, act.Versions(), tt.exp)
+			}
+		})
+	}
+
+	t.Run("should stop reading at the first event after the time", func(t *testing.T) {
+		db := &readRecorderDB{DB: salsa.NewMemoryDB[string]()}
+		sut := salsa.NewStore[string](db, salsa.WithResolver[state](er))
+
+		es := make([]salsa.Event[state], 150)
+		for i := range es {
+			es[i] = &event{Amount: 1}
+		}
+
+		_, err := sut.Append(context.Background(), "id", salsa.ExpectNoStream, es[:50]...)
+		assertErrorExists(t, err, false)
+
+		time.Sleep(time.Millisecond)
+		between := time.Now()
+		time.Sleep(time.Millisecond)
+
+		_, err = sut.Append(context.Background(), "id", 50, es[50:]...)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.GetAsOf(context.Background(), "id", between)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.State(), state{Balance: 50})
+		assertDeepEqual(t, db.reads, []string{"events:1", "events:1"})
+
+		db.reads = nil
+		act, err = sut.GetAsOf(context.Background(), "id", time.Now())
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.State(), state{Balance: 150})
+		assertDeepEqual(t, db.reads, []string{"events:1", "events:101", "events:1"})
+	})
+
+	t.Run("should replay events from the nearest db snapshot", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](2))
+
+		a := new(salsa.Aggregate[state])
+		for i := 0; i < 3; i++ {
+			_, err := a.Apply(&event{Amount: 10})
+			assertErrorExists(t, err, false)
+		}
+
+		err := sut.Save(context.Background(), "id", a)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.GetAsOf(context.Background(), "id", time.Now())
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.Versions(), salsa.Versions{State: 3, Initial: 3, Current: 3})
+		assertDeepEqual(t, act.State(), state{Balance: 30})
+	})
+
+	t.Run("should replay events from the nearest snapshot store snapshot", func(t *testing.T) {
+		sut := salsa.NewStoreWithSnapshots[string](salsa.NewMemoryDB[string](), salsa.NewMemorySnapshotStore[string](),
+			salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](2))
+
+		a := new(salsa.Aggregate[state])
+		for i := 0; i < 3; i++ {
+			_, err := a.Apply(&event{Amount: 10})
+			assertErrorExists(t, err, false)
+		}
+
+		err := sut.Save(context.Background(), "id", a)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.GetAsOf(context.Background(), "id", time.Now())
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.Versions(), salsa.Versions{State: 3, Initial: 3, Current: 3})
+		assertDeepEqual(t, act.State(), state{Balance: 30})
+	})
+}
+
+func TestStore_History(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](2))
+
+	a := new(salsa.Aggregate[state])
+	for i := 1; i <= 5; i++ {
+		_, err := a.Apply(&event{Amount: i})
+		assertErrorExists(t, err, false)
+	}
+
+	err := sut.Save(context.Background(), "id", a)
+	assertErrorExists(t, err, false)
+
+	tests := []struct {
+		name     string
+		id       string
+		from, to uint64
+		exp      []int
+		err      error
+	}{
+		{
+			name: "should return an error if the aggregate does not exist",
+			id:   "invalid",
+			from: 1,
+			err:  salsa.ErrNotFound,
+		},
+		{
+			name: "should return all events",
+			id:   "id",
+			from: 1,
+			exp:  []int{1, 2, 3, 4, 5},
+		},
+		{
+			name: "should return events within the range",
+			id:   "id",
+			from: 2,
+			to:   4,
+			exp:  []int{2, 3, 4},
+		},
+		{
+			name: "should return no events if the range is after the current version",
+			id:   "id",
+			from: 6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			act, err := sut.History(context.Background(), tt.id, tt.from, tt.to)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, expected %v", err, tt.err)
+			}
+
+			if act, exp := len(act), len(tt.exp); act != exp {
+				t.Fatalf("got %d, expected %d", act, exp)
+			}
+
+			for i, e := range act {
+				v := uint64(tt.exp[i])
+				assertDeepEqual(t, []any{e.ID, e.Version, e.Position, e.Event}, []any{tt.id, v, v, &event{Amount: tt.exp[i]}})
+
+				if e.Metadata.EventID == "" {
+					t.Error("got an empty event id, expected the persisted metadata")
+				}
+			}
+		})
+	}
+}
+
+func TestStore_GetOrNew(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+
+	t.Run("should return a new aggregate if the aggregate does not exist", func(t *testing.T) {
+		ok, err := sut.Exists(context.Background(), "id")
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, ok, false)
+
+		act, err := sut.GetOrNew(context.Background(), "id")
+		assertErrorExists(t, err, false)
+		assertAggregateEqual(t, act, aggregate{})
+
+		_, err = act.Apply(&event{Amount: 10})
+		assertErrorExists(t, err, false)
+
+		err = sut.Save(context.Background(), "id", act, salsa.WithNewStream())
+		assertErrorExists(t, err, false)
+	})
+
+	t.Run("should return the aggregate if it exists", func(t *testing.T) {
+		ok, err := sut.Exists(context.Background(), "id")
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, ok, true)
+
+		act, err := sut.GetOrNew(context.Background(), "id")
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.State(), state{Balance: 10})
+		assertDeepEqual(t, act.Versions(), salsa.Versions{Initial: 1, Current: 1})
+	})
+}
+
+func TestStore_SaveNewStream(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+
+	_, err := sut.Append(context.Background(), "id", salsa.ExpectNoStream, &event{Amount: 10}, &event{Amount: 20})
+	assertErrorExists(t, err, false)
+
+	tests := []struct {
+		name   string
+		id     string
+		events int
+		load   bool
+		exp    *salsa.ConflictError
+	}{
+		{
+			name:   "should save a new stream",
+			id:     "new",
+			events: 1,
+		},
+		{
+			name: "should save an empty new stream",
+			id:   "empty",
+		},
+		{
+			name:   "should return an error if the stream exists",
+			id:     "id",
+			events: 1,
+			exp:    &salsa.ConflictError{ID: "id", Expected: 0, Actual: 2},
+		},
+		{
+			name: "should return an error if an empty stream exists",
+			id:   "id",
+			exp:  &salsa.ConflictError{ID: "id", Expected: 0, Actual: 2},
+		},
+		{
+			name:   "should return an error if the aggregate was loaded",
+			id:     "id",
+			events: 1,
+			load:   true,
+			exp:    &salsa.ConflictError{ID: "id", Expected: 0, Actual: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := new(salsa.Aggregate[state])
+			if tt.load {
+				a, err = sut.Get(context.Background(), tt.id)
+				assertErrorExists(t, err, false)
+			}
+
+			for i := 0; i < tt.events; i++ {
+				_, err := a.Apply(&event{Amount: 10})
+				assertErrorExists(t, err, false)
+			}
+
+			err := sut.Save(context.Background(), tt.id, a, salsa.WithNewStream())
+			if tt.exp == nil {
+				assertErrorExists(t, err, false)
+				return
+			}
+
+			var cerr *salsa.ConflictError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("got %v, expected a conflict error", err)
+			}
+
+			assertDeepEqual(t, *cerr, *tt.exp)
+		})
+	}
+}
+
+func TestStore_SaveAll(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+
+	_, err := sut.Append(context.Background(), "b", salsa.ExpectNoStream, &event{Amount: 10})
+	assertErrorExists(t, err, false)
+
+	t.Run("should save all aggregates", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 10})
+		assertErrorExists(t, err, false)
+
+		b, err := sut.Get(context.Background(), "b")
+		assertErrorExists(t, err, false)
+
+		_, err = b.Apply(&event{Amount: 20})
+		assertErrorExists(t, err, false)
+
+		err = sut.SaveAll(context.Background(), map[string]*salsa.Aggregate[state]{"a": a, "b": b})
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, a.Versions(), salsa.Versions{Initial: 1, Current: 1})
+		assertDeepEqual(t, b.Versions(), salsa.Versions{Initial: 2, Current: 2})
+
+		act, err := sut.Get(context.Background(), "b")
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.State(), state{Balance: 30})
+	})
+
+	t.Run("should not save any aggregates if a conflict occurs", func(t *testing.T) {
+		a := new(salsa.Aggregate[state])
+		_, err := a.Apply(&event{Amount: 10})
+		assertErrorExists(t, err, false)
+
+		c := new(salsa.Aggregate[state])
+		_, err = c.Apply(&event{Amount: 10})
+		assertErrorExists(t, err, false)
+
+		err = sut.SaveAll(context.Background(), map[string]*salsa.Aggregate[state]{"a": a, "c": c})
+		if !errors.Is(err, salsa.ErrConflict) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrConflict)
+		}
+		assertDeepEqual(t, c.Versions(), salsa.Versions{Current: 1})
+
+		ok, err := sut.Exists(context.Background(), "c")
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, ok, false)
+	})
+
+	t.Run("should return an error if the db does not support multi writes", func(t *testing.T) {
+		db := struct{ salsa.DB[string] }{salsa.NewMemoryDB[string]()}
+		sut := salsa.NewStore[string](db, salsa.WithResolver[state](er))
+
+		err := sut.SaveAll(context.Background(), map[string]*salsa.Aggregate[state]{"a": new(salsa.Aggregate[state])})
+		if !errors.Is(err, salsa.ErrUnsupported) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrUnsupported)
+		}
+	})
+}
+
+func TestStore_Outbox(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	tests := []struct {
+		name string
+		db   salsa.DB[string]
+		exp  []uint64
+		err  error
+	}{
+		{
+			name: "should write saved and appended events to the outbox",
+			db:   salsa.NewMemoryDB[string](),
+			exp:  []uint64{1, 2, 3},
+		},
+		{
+			name: "should return an error if the outbox is not supported",
+			db:   txWrapperDB{salsa.NewMemoryDB[string]()},
+			err:  salsa.ErrUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := salsa.NewStore[string](tt.db, salsa.WithResolver[state](er), salsa.WithOutbox[state]())
+
+			a := new(salsa.Aggregate[state])
+			for i := 0; i < 2; i++ {
+				_, err := a.Apply(&event{Amount: 10})
+				assertErrorExists(t, err, false)
+			}
+
+			err := sut.Save(context.Background(), "id", a)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, expected %v", err, tt.err)
+			}
+
+			_, err = sut.Append(context.Background(), "id", salsa.ExpectAny, &event{Amount: 10})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, expected %v", err, tt.err)
+			}
+
+			odb, ok := tt.db.(salsa.OutboxDB[string])
+			if !ok {
+				return
+			}
+
+			es, err := odb.ReadOutbox(context.Background(), 0)
+			assertErrorExists(t, err, false)
+
+			var act []uint64
+			for _, e := range es {
+				act = append(act, e.Version)
+			}
+			assertDeepEqual(t, act, tt.exp)
+		})
+	}
+}
+
+// txWrapperDB wraps transactions to hide optional transaction interfaces
+type txWrapperDB struct {
+	salsa.DB[string]
+}
+
+func (d txWrapperDB) Write(ctx context.Context, id string, fn func(salsa.DBTx) error) error {
+	return d.DB.Write(ctx, id, func(tx salsa.DBTx) error {
+		return fn(struct{ salsa.DBTx }{tx})
+	})
+}
+
+func TestStore_Append(t *testing.T) {
+	errInvalid := errors.New("invalid")
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	tests := []struct {
+		name     string
+		setup    int
+		expected salsa.ExpectedVersion
+		version  uint64
+		err      error
+	}{
+		{
+			name:     "should append to the expected version",
+			setup:    2,
+			expected: 2,
+			version:  4,
+		},
+		{
+			name:     "should return an error if the version does not match",
+			setup:    2,
+			expected: 1,
+			err:      salsa.ErrConflict,
+		},
+		{
+			name:     "should append to a new stream",
+			expected: salsa.ExpectNoStream,
+			version:  2,
+		},
+		{
+			name:     "should return an error if the stream exists",
+			setup:    1,
+			expected: salsa.ExpectNoStream,
+			err:      salsa.ErrConflict,
+		},
+		{
+			name:     "should append to an existing stream",
+			setup:    3,
+			expected: salsa.ExpectStreamExists,
+			version:  5,
+		},
+		{
+			name:     "should return an error if the stream does not exist",
+			expected: salsa.ExpectStreamExists,
+			err:      salsa.ErrConflict,
+		},
+		{
+			name:     "should append to any stream",
+			setup:    1,
+			expected: salsa.ExpectAny,
+			version:  3,
+		},
+		{
+			name:     "should append to any new stream",
+			expected: salsa.ExpectAny,
+			version:  2,
+		},
+		{
+			name:     "should return an error if the expected version is invalid",
+			expected: -10,
+			err:      errInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const id = "id"
+			sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+
+			if tt.setup > 0 {
+				es := make([]salsa.Event[state], tt.setup)
+				for i := range es {
+					es[i] = &event{Amount: 10}
+				}
+
+				_, err := sut.Append(context.Background(), id, salsa.ExpectNoStream, es...)
+				assertErrorExists(t, err, false)
+			}
+
+			ctx := salsa.ContextWithCorrelationID(context.Background(), "correlationid")
+			act, err := sut.Append(ctx, id, tt.expected, &event{Amount: 10}, &event{Amount: 20})
+			if tt.err != nil {
+				assertErrorExists(t, err, true)
+				if tt.err != errInvalid && !errors.Is(err, tt.err) {
+					t.Errorf("got %v, expected %v", err, tt.err)
+				}
+				return
+			}
+
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act, tt.version)
+
+			a, err := sut.Get(context.Background(), id)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, a.Versions().Current, tt.version)
+			assertDeepEqual(t, a.State(), state{Balance: (tt.setup * 10) + 30})
+
+			ms := a.Metadata()
+			assertDeepEqual(t, ms[len(ms)-1].CorrelationID, "correlationid")
+		})
+	}
+
+	t.Run("should return consistent conflict errors for stream expectations", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+
+		_, err := sut.Append(context.Background(), "id", salsa.ExpectStreamExists, &event{Amount: 10})
+
+		var cerr *salsa.ConflictError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: "id", Expected: 1, Actual: 0})
+
+		_, err = sut.Append(context.Background(), "id", salsa.ExpectAny, &event{Amount: 10})
+		assertErrorExists(t, err, false)
+
+		_, err = sut.Append(context.Background(), "id", salsa.ExpectNoStream, &event{Amount: 10})
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected a conflict error", err)
+		}
+		assertDeepEqual(t, *cerr, salsa.ConflictError{ID: "id", Expected: 0, Actual: 1})
+	})
+}
+
+func TestStore_Snapshots(t *testing.T) {
+	const id = "id"
+
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	save := func(sut *salsa.Store[string, state], n int) {
+		a, err := sut.Get(context.Background(), id)
+		if errors.Is(err, salsa.ErrNotFound) {
+			a, err = new(salsa.Aggregate[state]), nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < n; i++ {
+			if _, err := a.Apply(&event{Amount: 10}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := sut.Save(context.Background(), id, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("should ignore stale snapshots", func(t *testing.T) {
+		db := salsa.NewMemoryDB[string]()
+		save(salsa.NewStore[string](db, salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](1)), 3)
+
+		vr := salsa.EventResolverFunc[versionedState](func(string) (salsa.Event[versionedState], error) {
+			return new(versionedStateEvent), nil
+		})
+
+		sut := salsa.NewStore[string](db, salsa.WithResolver[versionedState](vr), salsa.WithSnapshotRate[versionedState](1))
+
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.State(), versionedState{Total: 30})
+		assertDeepEqual(t, act.Versions(), salsa.Versions{State: 0, Initial: 3, Current: 3})
+
+		err = sut.Save(context.Background(), id, act)
+		assertErrorExists(t, err, false)
+
+		act, err = sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.State(), versionedState{Total: 30})
+		assertDeepEqual(t, act.Versions(), salsa.Versions{State: 3, Initial: 3, Current: 3})
+	})
+
+	t.Run("should purge aggregate snapshots", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](1))
+		save(sut, 2)
+		save(sut, 2)
+
+		err := sut.PurgeSnapshots(context.Background(), id)
+		assertErrorExists(t, err, false)
+
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.State(), state{Balance: 40})
+		assertDeepEqual(t, act.Versions(), salsa.Versions{State: 0, Initial: 4, Current: 4})
+	})
+
+	t.Run("should purge all snapshots", func(t *testing.T) {
+		sut := salsa.NewMemoryStore[string](salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](1))
+		save(sut, 2)
+
+		err := sut.PurgeAllSnapshots(context.Background())
+		assertErrorExists(t, err, false)
+
+		act, err := sut.Get(context.Background(), id)
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act.Versions(), salsa.Versions{State: 0, Initial: 2, Current: 2})
+	})
+}
+
+func TestStore_SnapshotStore(t *testing.T) {
+	const id = "id"
+
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	tests := []struct {
+		name   string
+		optFns []func(*salsa.Options[state])
+	}{
+		{
+			name:   "should write snapshots to the snapshot store",
+			optFns: []func(*salsa.Options[state]){salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](2)},
+		},
+		{
+			name: "should write background snapshots to the snapshot store",
+			optFns: []func(*salsa.Options[state]){
+				salsa.WithResolver[state](er),
+				salsa.WithSnapshotRate[state](2),
+				salsa.WithAsyncSnapshots[state](1),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, ss := salsa.NewMemoryDB[string](), salsa.NewMemorySnapshotStore[string]()
+			sut := salsa.NewStoreWithSnapshots[string](db, ss, tt.optFns...)
+
+			a := new(salsa.Aggregate[state])
+			for i := 0; i < 5; i++ {
+				_, err := a.Apply(&event{Amount: 10})
+				assertErrorExists(t, err, false)
+			}
+
+			err := sut.Save(context.Background(), id, a)
+			assertErrorExists(t, err, false)
+
+			err = sut.Flush(context.Background())
+			assertErrorExists(t, err, false)
+
+			es, err := ss.ReadState(context.Background(), id, 0)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, es.Version, uint64(5))
+
+			des, _, err := db.Read(context.Background(), id)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, des, salsa.EncodedState{})
+
+			act, err := sut.Get(context.Background(), id)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act.State(), state{Balance: 50})
+			assertDeepEqual(t, act.Versions(), salsa.Versions{State: 5, Initial: 5, Current: 5})
+
+			act, err = sut.GetAt(context.Background(), id, 3)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act.State(), state{Balance: 30})
+			assertDeepEqual(t, act.Versions(), salsa.Versions{State: 0, Initial: 3, Current: 3})
+
+			err = sut.PurgeSnapshots(context.Background(), id)
+			assertErrorExists(t, err, false)
+
+			act, err = sut.Get(context.Background(), id)
+			assertErrorExists(t, err, false)
+			assertDeepEqual(t, act.State(), state{Balance: 50})
+			assertDeepEqual(t, act.Versions(), salsa.Versions{State: 0, Initial: 5, Current: 5})
+		})
+	}
+
+	t.Run("should commit the aggregates if the snapshot write fails", func(t *testing.T) {
+		serr := errors.New("error")
+		ss := &errSnapshotStore{SnapshotStore: salsa.NewMemorySnapshotStore[string](), err: serr}
+		sut := salsa.NewStoreWithSnapshots[string](salsa.NewMemoryDB[string](), ss,
+			salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](1))
+
+		as := map[string]*salsa.Aggregate[state]{"a": new(salsa.Aggregate[state]), "b": new(salsa.Aggregate[state])}
+		for _, a := range as {
+			for i := 0; i < 2; i++ {
+				_, err := a.Apply(&event{Amount: 10})
+				assertErrorExists(t, err, false)
+			}
+		}
+
+		err := sut.SaveAll(context.Background(), as)
+		assertErrorExists(t, err, false)
+
+		for _, a := range as {
+			assertDeepEqual(t, len(a.Events()), 0)
+			assertDeepEqual(t, a.Versions(), salsa.Versions{Initial: 2, Current: 2})
+		}
+
+		err = sut.Flush(context.Background())
+		if !errors.Is(err, serr) {
+			t.Errorf("got %v, expected %v", err, serr)
+		}
+
+		err = sut.Flush(context.Background())
+		assertErrorExists(t, err, false)
 	})
+
+	t.Run("should return not found if the aggregate does not exist", func(t *testing.T) {
+		sut := salsa.NewStoreWithSnapshots[string](salsa.NewMemoryDB[string](), salsa.NewMemorySnapshotStore[string](),
+			salsa.WithResolver[state](er))
+
+		_, err := sut.Get(context.Background(), id)
+		if !errors.Is(err, salsa.ErrNotFound) {
+			t.Errorf("got %v, expected %v", err, salsa.ErrNotFound)
+		}
+	})
+}
+
+type (
+	versionedState struct {
+		Total int `json:"total"`
+	}
+
+	versionedStateEvent struct {
+		Amount int `json:"amount"`
+	}
+)
+
+func (s versionedState) SchemaVersion() uint {
+	return 1
+}
+
+func (e *versionedStateEvent) Type() string {
+	return "event"
+}
+
+func (e *versionedStateEvent) Apply(s versionedState) (versionedState, error) {
+	s.Total += e.Amount
+	return s, nil
+}
+
+func TestMemoryDB(t *testing.T) {
+	salsatest.RunDBSuite(t, func() salsa.DB[string] {
+		return salsa.NewMemoryDB[string]()
+	})
+}
+
+func TestMemorySnapshotStore(t *testing.T) {
+	salsatest.RunSnapshotStoreSuite(t, func() salsa.SnapshotStore[string] {
+		return salsa.NewMemorySnapshotStore[string]()
+	})
+}
+
+// errSnapshotStore returns the configured error for state writes
+type errSnapshotStore struct {
+	salsa.SnapshotStore[string]
+	err error
+}
+
+func (s *errSnapshotStore) WriteState(context.Context, string, salsa.EncodedState) error {
+	return s.err
 }
@@ -0,0 +1,160 @@
+package salsa
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// Subscription represents a catch-up subscription to the global stream
+	Subscription[TI comparable, TS any] struct {
+		name  string
+		store *Store[TI, TS]
+		fn    func(context.Context, RecordedEvent[TI, TS]) error
+		opts  SubscriptionOptions
+	}
+
+	// SubscriptionOptions represents a set of subscription options
+	SubscriptionOptions struct {
+		BatchSize    int
+		PollInterval time.Duration
+		Checkpoints  CheckpointStore
+	}
+
+	// CheckpointStore represents a subscription checkpoint store
+	CheckpointStore interface {
+		Load(ctx context.Context, name string) (uint64, error)
+		Save(ctx context.Context, name string, position uint64) error
+	}
+
+	memCheckpointStore struct {
+		positions map[string]uint64
+		mu        sync.RWMutex
+	}
+)
+
+// NewSubscription returns a new subscription with the specified name that invokes fn for each event.
+// Events are delivered at least once, so fn should be idempotent.
+func NewSubscription[TI comparable, TS any](s *Store[TI, TS], name string, fn func(context.Context, RecordedEvent[TI, TS]) error, optFns ...func(*SubscriptionOptions)) *Subscription[TI, TS] {
+	o := SubscriptionOptions{
+		BatchSize:    100,
+		PollInterval: time.Second,
+		Checkpoints:  NewMemoryCheckpointStore(),
+	}
+
+	for _, fn := range optFns {
+		fn(&o)
+	}
+
+	return &Subscription[TI, TS]{
+		name:  name,
+		store: s,
+		fn:    fn,
+		opts:  o,
+	}
+}
+
+// Run replays all events after the last checkpoint and then polls for new events until
+// the context is cancelled or an error occurs
+func (s *Subscription[TI, TS]) Run(ctx context.Context) error {
+	for {
+		if err := s.CatchUp(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.opts.PollInterval):
+		}
+	}
+}
+
+// CatchUp handles all events after the last checkpoint and returns once no further events exist.
+// Events that the store resolver does not recognise are skipped.
+func (s *Subscription[TI, TS]) CatchUp(ctx context.Context) error {
+	pos, err := s.opts.Checkpoints.Load(ctx, s.name)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		es, err := s.store.readAll(ctx, pos+1, s.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		cp := pos
+		for _, e := range es {
+			// events of unknown types are skipped, but advance the checkpoint
+			if e.Event != nil {
+				if err = s.fn(ctx, e); err != nil {
+					break
+				}
+			}
+			pos = e.Position
+		}
+
+		if pos != cp {
+			if serr := s.opts.Checkpoints.Save(ctx, s.name, pos); serr != nil {
+				return serr
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if s.opts.BatchSize < 1 || len(es) < s.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// NewMemoryCheckpointStore returns a new in-memory checkpoint store
+func NewMemoryCheckpointStore() CheckpointStore {
+	return &memCheckpointStore{positions: map[string]uint64{}}
+}
+
+// Load returns the checkpoint position for the specified subscription
+func (s *memCheckpointStore) Load(ctx context.Context, name string) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.positions[name], nil
+}
+
+// Save saves the checkpoint position for the specified subscription
+func (s *memCheckpointStore) Save(ctx context.Context, name string, position uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.positions[name] = position
+	return nil
+}
+
+// WithBatchSize configures the subscription to read the specified number of events at a time
+func WithBatchSize(n int) func(*SubscriptionOptions) {
+	return func(o *SubscriptionOptions) {
+		o.BatchSize = n
+	}
+}
+
+// WithPollInterval configures the subscription to poll for new events at the specified interval
+func WithPollInterval(d time.Duration) func(*SubscriptionOptions) {
+	return func(o *SubscriptionOptions) {
+		o.PollInterval = d
+	}
+}
+
+// WithCheckpointStore configures the subscription to use the specified checkpoint store
+func WithCheckpointStore(cs CheckpointStore) func(*SubscriptionOptions) {
+	return func(o *SubscriptionOptions) {
+		o.Checkpoints = cs
+	}
+}
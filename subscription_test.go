@@ -0,0 +1,242 @@
+package salsa_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stevecallear/salsa"
+)
+
+func TestSubscription_Run(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	str := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+	cps := salsa.NewMemoryCheckpointStore()
+
+	save := func(id string, amounts ...int) {
+		a := new(salsa.Aggregate[state])
+		for _, amt := range amounts {
+			if _, err := a.Apply(&event{Amount: amt}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := str.Save(context.Background(), id, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(ctx context.Context, n int, fn func(salsa.RecordedEvent[string, state]) error) ([]int, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var mu sync.Mutex
+		var act []int
+
+		sut := salsa.NewSubscription(str, "sub", func(ctx context.Context, e salsa.RecordedEvent[string, state]) error {
+			if err := fn(e); err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			act = append(act, e.Event.(*event).Amount)
+			if len(act) >= n {
+				cancel()
+			}
+			return nil
+		}, salsa.WithBatchSize(2), salsa.WithPollInterval(time.Millisecond), salsa.WithCheckpointStore(cps))
+
+		err := sut.Run(ctx)
+		return act, err
+	}
+
+	noop := func(salsa.RecordedEvent[string, state]) error { return nil }
+
+	t.Run("should replay existing events", func(t *testing.T) {
+		save("a", 1, 2)
+		save("b", 3)
+
+		act, err := run(context.Background(), 3, noop)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, expected %v", err, context.Canceled)
+		}
+		assertDeepEqual(t, act, []int{1, 2, 3})
+	})
+
+	t.Run("should deliver new events from the checkpoint", func(t *testing.T) {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			save("c", 4, 5)
+		}()
+
+		act, err := run(context.Background(), 2, noop)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, expected %v", err, context.Canceled)
+		}
+		assertDeepEqual(t, act, []int{4, 5})
+	})
+
+	t.Run("should return handler errors and redeliver the failed event", func(t *testing.T) {
+		save("d", 6, 7)
+
+		act, err := run(context.Background(), 2, func(e salsa.RecordedEvent[string, state]) error {
+			if e.Event.(*event).Amount == 7 {
+				return errors.New("error")
+			}
+			return nil
+		})
+		assertErrorExists(t, err, true)
+		assertDeepEqual(t, act, []int{6})
+
+		act, err = run(context.Background(), 1, noop)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, expected %v", err, context.Canceled)
+		}
+		assertDeepEqual(t, act, []int{7})
+	})
+
+	t.Run("should return checkpoint errors", func(t *testing.T) {
+		sut := salsa.NewSubscription(str, "sub", func(context.Context, salsa.RecordedEvent[string, state]) error {
+			return nil
+		}, salsa.WithCheckpointStore(errCheckpointStore{}))
+
+		err := sut.Run(context.Background())
+		assertErrorExists(t, err, true)
+	})
+}
+
+type errCheckpointStore struct{}
+
+func (errCheckpointStore) Load(context.Context, string) (uint64, error) {
+	return 0, errors.New("error")
+}
+
+func (errCheckpointStore) Save(context.Context, string, uint64) error {
+	return errors.New("error")
+}
+
+func TestSubscription_CatchUp(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	str := salsa.NewMemoryStore[string](salsa.WithResolver[state](er))
+
+	a := new(salsa.Aggregate[state])
+	for i := 1; i <= 5; i++ {
+		if _, err := a.Apply(&event{Amount: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := str.Save(context.Background(), "id", a); err != nil {
+		t.Fatal(err)
+	}
+
+	var act []int
+	sut := salsa.NewSubscription(str, "sub", func(ctx context.Context, e salsa.RecordedEvent[string, state]) error {
+		act = append(act, e.Event.(*event).Amount)
+		return nil
+	}, salsa.WithBatchSize(2))
+
+	t.Run("should handle all events and return", func(t *testing.T) {
+		err := sut.CatchUp(context.Background())
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act, []int{1, 2, 3, 4, 5})
+	})
+
+	t.Run("should not redeliver handled events", func(t *testing.T) {
+		err := sut.CatchUp(context.Background())
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act, []int{1, 2, 3, 4, 5})
+	})
+}
+
+func TestSubscription_SharedDB(t *testing.T) {
+	db := salsa.NewMemoryDB[string]()
+
+	accounts := salsa.NewStore[string](db, salsa.WithResolver[state](salsa.EventResolverFunc[state](func(et string) (salsa.Event[state], error) {
+		if et != new(event).Type() {
+			return nil, salsa.ErrUnknownEvent
+		}
+		return new(event), nil
+	})))
+
+	counters := salsa.NewStore[string](db, salsa.WithResolver[counter](salsa.EventResolverFunc[counter](func(et string) (salsa.Event[counter], error) {
+		if et != new(incremented).Type() {
+			return nil, salsa.ErrUnknownEvent
+		}
+		return new(incremented), nil
+	})))
+
+	_, err := accounts.Append(context.Background(), "a", salsa.ExpectAny, &event{Amount: 1})
+	assertErrorExists(t, err, false)
+
+	_, err = counters.Append(context.Background(), "c", salsa.ExpectAny, &incremented{}, &incremented{})
+	assertErrorExists(t, err, false)
+
+	_, err = accounts.Append(context.Background(), "a", salsa.ExpectAny, &event{Amount: 2})
+	assertErrorExists(t, err, false)
+
+	t.Run("should skip events of unknown types when reading the global stream", func(t *testing.T) {
+		es, err := accounts.ReadAll(context.Background(), 1, 0)
+		assertErrorExists(t, err, false)
+
+		var act []uint64
+		for _, e := range es {
+			act = append(act, e.Position)
+		}
+		assertDeepEqual(t, act, []uint64{1, 4})
+	})
+
+	t.Run("should skip events of unknown types and advance the checkpoint", func(t *testing.T) {
+		cps := salsa.NewMemoryCheckpointStore()
+
+		var act []int
+		sut := salsa.NewSubscription(accounts, "sub", func(ctx context.Context, e salsa.RecordedEvent[string, state]) error {
+			act = append(act, e.Event.(*event).Amount)
+			return nil
+		}, salsa.WithBatchSize(2), salsa.WithCheckpointStore(cps))
+
+		err := sut.CatchUp(context.Background())
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, act, []int{1, 2})
+
+		pos, err := cps.Load(context.Background(), "sub")
+		assertErrorExists(t, err, false)
+		assertDeepEqual(t, pos, uint64(4))
+	})
+
+	t.Run("should return resolver errors", func(t *testing.T) {
+		sut := salsa.NewStore[string](db, salsa.WithResolver[state](salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+			return nil, errors.New("error")
+		})))
+
+		_, err := sut.ReadAll(context.Background(), 1, 0)
+		assertErrorExists(t, err, true)
+	})
+}
+
+type (
+	counter struct {
+		Count int `json:"count"`
+	}
+
+	incremented struct{}
+)
+
+func (e *incremented) Type() string {
+	return "incremented"
+}
+
+func (e *incremented) Apply(c counter) (counter, error) {
+	c.Count++
+	return c, nil
+}
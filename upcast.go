@@ -0,0 +1,124 @@
+package salsa
+
+import "errors"
+
+type (
+	// SchemaVersioner represents an event or state with a schema version
+	SchemaVersioner interface {
+		SchemaVersion() uint
+	}
+
+	// UpcastKey represents the event type and schema version handled by an upcaster
+	UpcastKey struct {
+		Type   string
+		Schema uint
+	}
+
+	// Upcaster represents an encoded event upcaster
+	Upcaster interface {
+		Upcast(e EncodedEvent) (EncodedEvent, error)
+	}
+
+	// UpcasterFunc represents an encoded event upcaster func
+	UpcasterFunc func(e EncodedEvent) (EncodedEvent, error)
+
+	// StateUpcaster represents an encoded state upcaster
+	StateUpcaster interface {
+		Upcast(s EncodedState) (EncodedState, error)
+	}
+
+	// StateUpcasterFunc represents an encoded state upcaster func
+	StateUpcasterFunc func(s EncodedState) (EncodedState, error)
+)
+
+var errUpcastCycle = errors.New("upcaster cycle detected")
+
+// Upcast upcasts the encoded event
+func (fn UpcasterFunc) Upcast(e EncodedEvent) (EncodedEvent, error) {
+	return fn(e)
+}
+
+// Upcast upcasts the encoded state
+func (fn StateUpcasterFunc) Upcast(s EncodedState) (EncodedState, error) {
+	return fn(s)
+}
+
+// upcastEvent applies the upcaster chain to the encoded event. If an upcaster
+// does not change the event type or schema then the schema is incremented.
+func upcastEvent(ucs map[UpcastKey]Upcaster, e EncodedEvent) (EncodedEvent, error) {
+	for i := 0; i <= len(ucs); i++ {
+		k := UpcastKey{Type: e.Type, Schema: e.Schema}
+
+		uc, ok := ucs[k]
+		if !ok {
+			return e, nil
+		}
+
+		var err error
+		if e, err = uc.Upcast(e); err != nil {
+			return EncodedEvent{}, err
+		}
+
+		if e.Type == k.Type && e.Schema == k.Schema {
+			e.Schema++
+		}
+	}
+
+	return EncodedEvent{}, errUpcastCycle
+}
+
+// upcastState applies the upcaster chain to the encoded state. If an upcaster
+// does not change the schema then it is incremented.
+func upcastState(ucs map[uint]StateUpcaster, s EncodedState) (EncodedState, error) {
+	for i := 0; i <= len(ucs); i++ {
+		k := s.Schema
+
+		uc, ok := ucs[k]
+		if !ok {
+			return s, nil
+		}
+
+		var err error
+		if s, err = uc.Upcast(s); err != nil {
+			return EncodedState{}, err
+		}
+
+		if s.Schema == k {
+			s.Schema++
+		}
+	}
+
+	return EncodedState{}, errUpcastCycle
+}
+
+func schemaVersion[T any](v T) uint {
+	if sv, ok := any(v).(SchemaVersioner); ok {
+		return sv.SchemaVersion()
+	}
+
+	if sv, ok := any(&v).(SchemaVersioner); ok {
+		return sv.SchemaVersion()
+	}
+
+	return 0
+}
+
+// WithUpcaster configures the store to upcast events of the specified type and schema version
+func WithUpcaster[T any](eventType string, schema uint, u Upcaster) func(*Options[T]) {
+	return func(o *Options[T]) {
+		if o.Upcasters == nil {
+			o.Upcasters = map[UpcastKey]Upcaster{}
+		}
+		o.Upcasters[UpcastKey{Type: eventType, Schema: schema}] = u
+	}
+}
+
+// WithStateUpcaster configures the store to upcast snapshot state of the specified schema version
+func WithStateUpcaster[T any](schema uint, u StateUpcaster) func(*Options[T]) {
+	return func(o *Options[T]) {
+		if o.StateUpcasters == nil {
+			o.StateUpcasters = map[uint]StateUpcaster{}
+		}
+		o.StateUpcasters[schema] = u
+	}
+}
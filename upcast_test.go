@@ -0,0 +1,218 @@
+package salsa_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stevecallear/salsa"
+)
+
+func TestStore_Upcast(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(et string) (salsa.Event[state], error) {
+		if et != new(event).Type() {
+			return nil, errors.New("invalid event type")
+		}
+		return new(event), nil
+	})
+
+	double := salsa.UpcasterFunc(func(e salsa.EncodedEvent) (salsa.EncodedEvent, error) {
+		var v map[string]int
+		if err := json.Unmarshal(e.Data, &v); err != nil {
+			return e, err
+		}
+
+		v["amount"] *= 2
+
+		var err error
+		e.Data, err = json.Marshal(v)
+		return e, err
+	})
+
+	rename := salsa.UpcasterFunc(func(e salsa.EncodedEvent) (salsa.EncodedEvent, error) {
+		e.Type = new(event).Type()
+		return e, nil
+	})
+
+	tests := []struct {
+		name   string
+		opts   []func(*salsa.Options[state])
+		events []salsa.Event[state]
+		exp    state
+		err    bool
+	}{
+		{
+			name:   "should return upcaster errors",
+			opts:   []func(*salsa.Options[state]){salsa.WithUpcaster[state]("event", 0, errUpcaster)},
+			events: []salsa.Event[state]{&event{Amount: 10}},
+			err:    true,
+		},
+		{
+			name: "should return an error if the chain contains a cycle",
+			opts: []func(*salsa.Options[state]){
+				salsa.WithUpcaster[state]("legacy", 0, salsa.UpcasterFunc(func(e salsa.EncodedEvent) (salsa.EncodedEvent, error) {
+					e.Type = "other"
+					return e, nil
+				})),
+				salsa.WithUpcaster[state]("other", 0, salsa.UpcasterFunc(func(e salsa.EncodedEvent) (salsa.EncodedEvent, error) {
+					e.Type = "legacy"
+					return e, nil
+				})),
+			},
+			events: []salsa.Event[state]{&legacyEvent{Amount: 10}},
+			err:    true,
+		},
+		{
+			name:   "should transform event data",
+			opts:   []func(*salsa.Options[state]){salsa.WithUpcaster[state]("event", 0, double)},
+			events: []salsa.Event[state]{&event{Amount: 10}, &event{Amount: 20}},
+			exp:    state{Balance: 60},
+		},
+		{
+			name: "should apply the upcaster chain",
+			opts: []func(*salsa.Options[state]){
+				salsa.WithUpcaster[state]("event", 0, double),
+				salsa.WithUpcaster[state]("event", 1, double),
+			},
+			events: []salsa.Event[state]{&event{Amount: 10}},
+			exp:    state{Balance: 40},
+		},
+		{
+			name: "should rename event types",
+			opts: []func(*salsa.Options[state]){
+				salsa.WithUpcaster[state]("legacy", 0, rename),
+				salsa.WithUpcaster[state]("event", 0, double),
+			},
+			events: []salsa.Event[state]{&legacyEvent{Amount: 10}},
+			exp:    state{Balance: 20},
+		},
+		{
+			name:   "should use the event schema version",
+			opts:   []func(*salsa.Options[state]){salsa.WithUpcaster[state]("event", 0, double)},
+			events: []salsa.Event[state]{&versionedEvent{event{Amount: 10}}},
+			exp:    state{Balance: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := salsa.NewMemoryStore[string](append(tt.opts, salsa.WithResolver[state](er))...)
+
+			a := new(salsa.Aggregate[state])
+			for _, e := range tt.events {
+				if _, err := a.Apply(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := sut.Save(context.Background(), "id", a); err != nil {
+				t.Fatal(err)
+			}
+
+			act, err := sut.Get(context.Background(), "id")
+			assertErrorExists(t, err, tt.err)
+			if err != nil {
+				return
+			}
+
+			assertDeepEqual(t, act.State(), tt.exp)
+		})
+	}
+}
+
+func TestStore_UpcastState(t *testing.T) {
+	er := salsa.EventResolverFunc[state](func(string) (salsa.Event[state], error) {
+		return new(event), nil
+	})
+
+	vr := salsa.EventResolverFunc[versionedState](func(string) (salsa.Event[versionedState], error) {
+		return new(versionedStateEvent), nil
+	})
+
+	tests := []struct {
+		name     string
+		upcaster salsa.StateUpcasterFunc
+		exp      salsa.VersionedState[versionedState]
+		err      bool
+	}{
+		{
+			name: "should return upcaster errors",
+			upcaster: func(s salsa.EncodedState) (salsa.EncodedState, error) {
+				return s, errors.New("error")
+			},
+			err: true,
+		},
+		{
+			name: "should upcast snapshot state",
+			upcaster: func(s salsa.EncodedState) (salsa.EncodedState, error) {
+				var v map[string]int
+				if err := json.Unmarshal(s.Data, &v); err != nil {
+					return s, err
+				}
+
+				var err error
+				s.Data, err = json.Marshal(map[string]int{"total": v["balance"] * 10})
+				return s, err
+			},
+			exp: salsa.VersionedState[versionedState]{
+				Version: 1,
+				State:   versionedState{Total: 100},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := salsa.NewMemoryDB[string]()
+
+			a := new(salsa.Aggregate[state])
+			if _, err := a.Apply(&event{Amount: 10}); err != nil {
+				t.Fatal(err)
+			}
+
+			str := salsa.NewStore[string](db, salsa.WithResolver[state](er), salsa.WithSnapshotRate[state](0))
+			if err := str.Save(context.Background(), "id", a); err != nil {
+				t.Fatal(err)
+			}
+
+			sut := salsa.NewStore[string](db, salsa.WithResolver[versionedState](vr), salsa.WithStateUpcaster[versionedState](0, tt.upcaster))
+
+			act, err := sut.Get(context.Background(), "id")
+			assertErrorExists(t, err, tt.err)
+			if err != nil {
+				return
+			}
+
+			assertDeepEqual(t, act.State(), tt.exp.State)
+			assertDeepEqual(t, act.Versions().State, tt.exp.Version)
+		})
+	}
+}
+
+type (
+	legacyEvent struct {
+		Amount int `json:"amount"`
+	}
+
+	versionedEvent struct {
+		event
+	}
+)
+
+var errUpcaster = salsa.UpcasterFunc(func(e salsa.EncodedEvent) (salsa.EncodedEvent, error) {
+	return e, errors.New("error")
+})
+
+func (e *legacyEvent) Type() string {
+	return "legacy"
+}
+
+func (e *legacyEvent) Apply(s state) (state, error) {
+	s.Balance += e.Amount
+	return s, nil
+}
+
+func (e *versionedEvent) SchemaVersion() uint {
+	return 1
+}